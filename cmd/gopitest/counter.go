@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+// runCounter prints the value of a counter, after resetting it if reset is set.
+// If duration is not 0 it samples the counter every period and prints the change and the rate,
+// until interrupted or for duration if > 0.
+func runCounter(ctrl *gopicontrol.RevPiControl, name string, reset bool, period, duration time.Duration) error {
+	counter, err := gopicontrol.NewCounter(ctrl, name)
+	if err != nil {
+		return err
+	}
+	if reset {
+		if err = counter.Reset(); err != nil {
+			return err
+		}
+		fmt.Printf("counter %s of input %d reset\n", name, counter.Input())
+	}
+
+	s, err := counter.Sample()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d\n", name, s.Raw)
+	if duration == 0 {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if s, err = counter.Sample(); err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d, delta %d, total %d, rate %.2f/s\n", name, s.Raw, s.Delta, s.Total, s.Rate)
+	}
+}
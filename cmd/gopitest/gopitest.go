@@ -1,11 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/mezzato/revpi/pkg/gopicontrol"
+	"github.com/mezzato/revpi/pkg/pisim"
 )
 
 func main() {
@@ -16,9 +19,17 @@ func main() {
 	lsCmd := flag.NewFlagSet("ls", flag.ExitOnError)
 	resetCmd := flag.NewFlagSet("reset", flag.ExitOnError)
 	variableCmd := flag.NewFlagSet("variable", flag.ExitOnError)
+	varsCmd := flag.NewFlagSet("vars", flag.ExitOnError)
+	watchdogCmd := flag.NewFlagSet("watchdog", flag.ExitOnError)
+	stopioCmd := flag.NewFlagSet("stopio", flag.ExitOnError)
+	gatewayConfigCmd := flag.NewFlagSet("gateway-config", flag.ExitOnError)
+	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
+	ledCmd := flag.NewFlagSet("led", flag.ExitOnError)
+	hwWatchdogCmd := flag.NewFlagSet("hw-watchdog", flag.ExitOnError)
+	counterCmd := flag.NewFlagSet("counter", flag.ExitOnError)
 
 	readCmdVarName := readCmd.String("n", "", "variable name. (required)")
-	readCmdVarFormat := readCmd.String("f", "d", "variable format. (optional)")
+	readCmdVarFormat := readCmd.String("f", "d", "variable format: d decimal, s signed decimal, h hex, b binary. (optional)")
 
 	// List subcommand flag pointers
 	writeCmdVarName := writeCmd.String("n", "", "variable name. (required)")
@@ -26,16 +37,66 @@ func main() {
 
 	variableCmdVarName := variableCmd.String("n", "", "variable name. (required)")
 
+	varsCmdAddress := varsCmd.Int("a", -1, "address of the device, all devices if negative. (optional)")
+	varsCmdType := varsCmd.String("t", "", "variable type: input, output, memory or config. (optional)")
+	varsCmdPattern := varsCmd.String("n", "", "variable name pattern, e.g. 'I_*'. (optional)")
+	varsCmdExported := varsCmd.Bool("e", false, "only exported variables. (optional)")
+
+	watchdogCmdTimeout := watchdogCmd.Duration("t", time.Second, "watchdog timeout. (optional)")
+	watchdogCmdVarName := watchdogCmd.String("n", "O_1", "output variable owned by this test, rewritten to feed the watchdog; not RevPiLED or outputs written by other programs. (optional)")
+	watchdogCmdPeriod := watchdogCmd.Duration("p", 100*time.Millisecond, "feeding period. (optional)")
+	watchdogCmdDuration := watchdogCmd.Duration("d", 5*time.Second, "feeding duration before simulating a hang. (optional)")
+
+	stopioCmdMode := stopioCmd.Int("v", gopicontrol.IOStop, "1 to stop, 0 to start, 2 to toggle the I/O communication. (optional)")
+
+	gatewayConfigCmdFile := gatewayConfigCmd.String("f", "", "configuration file to download. (required)")
+	gatewayConfigCmdLeft := gatewayConfigCmd.Bool("left", false, "download to the gateway on the left of the RevPi Core instead of the right. (optional)")
+
+	lsCmdJSON := lsCmd.Bool("json", false, "print the devices as JSON. (optional)")
+
+	statusCmdJSON := statusCmd.Bool("json", false, "print the status as JSON. (optional)")
+
+	ledCmdBlink := ledCmd.Duration("blink", 0, "blink interval, e.g. 500ms. (optional)")
+	ledCmdDuration := ledCmd.Duration("d", 0, "blink duration, until interrupted if 0. (optional)")
+	var ledArgs []string
+
+	hwWatchdogCmdPeriod := hwWatchdogCmd.Duration("p", gopicontrol.DefaultWatchdogPeriod, "trigger period. (optional)")
+	hwWatchdogCmdFile := hwWatchdogCmd.String("file", "", "file the application touches to signal it is alive. (optional)")
+	hwWatchdogCmdMaxAge := hwWatchdogCmd.Duration("max-age", 30*time.Second, "maximum age of the -file modification time. (optional)")
+	hwWatchdogCmdCommand := hwWatchdogCmd.String("cmd", "", "shell command checking the application health, exit status 0 if healthy. (optional)")
+	hwWatchdogCmdDuration := hwWatchdogCmd.Duration("d", 0, "run duration, until interrupted if 0. (optional)")
+
+	counterCmdVarName := counterCmd.String("n", "", "counter or encoder variable name, e.g. Counter_1. (required)")
+	counterCmdReset := counterCmd.Bool("r", false, "reset the counter before reading. (optional)")
+	counterCmdPeriod := counterCmd.Duration("p", time.Second, "sampling period. (optional)")
+	counterCmdDuration := counterCmd.Duration("d", 0, "sampling duration, one reading if 0, until interrupted if negative. (optional)")
+
+	// common flags
+	var devicePath, configFile, simConfig string
+	for _, fs := range []*flag.FlagSet{readCmd, writeCmd, lsCmd, resetCmd, variableCmd, varsCmd, watchdogCmd, stopioCmd, gatewayConfigCmd, statusCmd, ledCmd, hwWatchdogCmd, counterCmd} {
+		fs.StringVar(&devicePath, "device", gopicontrol.PICONTROL_DEVICE, "piControl device file. (optional)")
+		fs.StringVar(&configFile, "config", "", "piCtory config.rsc loaded by the driver, default locations if empty. (optional)")
+		fs.StringVar(&simConfig, "sim", "", "piCtory config.rsc to run against a simulated driver instead of the device. (optional)")
+	}
+
 	// Verify that a subcommand has been provided
 	// os.Arg[0] is the main command
 	// os.Arg[1] will be the subcommand
 	if len(os.Args) < 2 || os.Args[1] == "-h" {
-		fmt.Printf(`a subcommand is required, valid options are [read|write|ls|reset]:
+		fmt.Printf(`a subcommand is required, valid options are [read|write|variable|vars|ls|reset|watchdog|stopio|gateway-config|status|led|hw-watchdog|counter]:
 read:     read variable value
 write:    write variable value
 variable: show variable info
+vars:     list variables
 ls:       list devices
 reset:    reset the driver
+watchdog: test the output watchdog
+stopio:   stop or start the I/O communication with the modules
+gateway-config: download a configuration to a master gateway
+status:   show the RevPi Core status, exit code 2 if modules are missing or mismatched
+led:      set the front LEDs or the X2 output, e.g. led A1 red -blink 500ms
+hw-watchdog: trigger the RevPi Connect hardware watchdog while the health checks pass
+counter:  read or reset a DIO/DI counter and show its rate, e.g. counter -n Counter_1 -d 10s
 
 Type 
 %s -h
@@ -43,7 +104,7 @@ for help with a verb
 
 For example to read the RevPi Core LED:
 %s read -n RevPiLED
-`, os.Args[0])
+`, os.Args[0], os.Args[0])
 		os.Exit(1)
 	}
 
@@ -58,8 +119,24 @@ For example to read the RevPi Core LED:
 		readCmd.Parse(os.Args[2:])
 	case "variable":
 		variableCmd.Parse(os.Args[2:])
+	case "vars":
+		varsCmd.Parse(os.Args[2:])
 	case "ls":
 		lsCmd.Parse(os.Args[2:])
+	case "watchdog":
+		watchdogCmd.Parse(os.Args[2:])
+	case "stopio":
+		stopioCmd.Parse(os.Args[2:])
+	case "gateway-config":
+		gatewayConfigCmd.Parse(os.Args[2:])
+	case "status":
+		statusCmd.Parse(os.Args[2:])
+	case "led":
+		ledArgs = parseWithArgs(ledCmd, os.Args[2:])
+	case "hw-watchdog":
+		hwWatchdogCmd.Parse(os.Args[2:])
+	case "counter":
+		counterCmd.Parse(os.Args[2:])
 	case "reset":
 		resetCmd.Parse(os.Args[2:])
 	default:
@@ -68,7 +145,17 @@ For example to read the RevPi Core LED:
 		os.Exit(1)
 	}
 
-	rpctl := gopicontrol.NewRevPiControl()
+	device := gopicontrol.NewDeviceBackend(devicePath)
+	device.ConfigFile = configFile
+	rpctl := gopicontrol.NewRevPiControlWithBackend(device)
+	if simConfig != "" {
+		sim, err := pisim.Load(simConfig)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		rpctl = gopicontrol.NewRevPiControlWithBackend(sim)
+	}
 	defer rpctl.Close()
 
 	// Check which subcommand was Parsed using the FlagSet.Parsed() function. Handle each case accordingly.
@@ -116,15 +203,102 @@ For example to read the RevPi Core LED:
 		}
 	}
 
+	if varsCmd.Parsed() {
+		filter := variableFilter{
+			address:  *varsCmdAddress,
+			typ:      *varsCmdType,
+			pattern:  *varsCmdPattern,
+			exported: *varsCmdExported,
+		}
+		if err := showVariables(rpctl, filter); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
 	if lsCmd.Parsed() {
 
-		devices, err := rpctl.GetDeviceInfoList()
+		devices, err := rpctl.Devices()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if err := showDeviceList(devices, *lsCmdJSON); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if watchdogCmd.Parsed() {
+		if err := runOutputWatchdog(rpctl, *watchdogCmdVarName, *watchdogCmdTimeout, *watchdogCmdPeriod, *watchdogCmdDuration); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if stopioCmd.Parsed() {
+		if err := setIOCommunication(rpctl, *stopioCmdMode); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if gatewayConfigCmd.Parsed() {
+		// Required Flags
+		if *gatewayConfigCmdFile == "" {
+			gatewayConfigCmd.PrintDefaults()
+			os.Exit(1)
+		}
+
+		side := gopicontrol.GatewayRight
+		if *gatewayConfigCmdLeft {
+			side = gopicontrol.GatewayLeft
+		}
+		if err := downloadGatewayConfig(rpctl, *gatewayConfigCmdFile, side); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if statusCmd.Parsed() {
+		status, err := rpctl.CoreStatus()
 		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err = showCoreStatus(status, *statusCmdJSON); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !status.Healthy() {
+			rpctl.Close()
+			os.Exit(2)
+		}
+	}
+
+	if ledCmd.Parsed() {
+		if err := runLED(rpctl, ledArgs, *ledCmdBlink, *ledCmdDuration); err != nil {
 			fmt.Println(err)
 			return
 		}
+	}
+
+	if hwWatchdogCmd.Parsed() {
+		if err := runHWWatchdog(rpctl, *hwWatchdogCmdPeriod, *hwWatchdogCmdFile, *hwWatchdogCmdMaxAge, *hwWatchdogCmdCommand, *hwWatchdogCmdDuration); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if counterCmd.Parsed() {
+		// Required Flags
+		if *counterCmdVarName == "" {
+			counterCmd.PrintDefaults()
+			os.Exit(1)
+		}
 
-		if err := showDeviceList(devices); err != nil {
+		if err := runCounter(rpctl, *counterCmdVarName, *counterCmdReset, *counterCmdPeriod, *counterCmdDuration); err != nil {
 			fmt.Println(err)
 			return
 		}
@@ -141,38 +315,97 @@ For example to read the RevPi Core LED:
 
 }
 
-func showDeviceList(asDevList []gopicontrol.SDeviceInfo) (err error) {
-	devcount := len(asDevList)
-
-	fmt.Printf("Found %d devices:\n", devcount)
-	for dev := 0; dev < devcount; dev++ {
-		mn := gopicontrol.GetModuleName(asDevList[dev].I16uModuleType)
+func showDeviceList(devices []gopicontrol.Device, asJSON bool) (err error) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(devices)
+	}
 
+	fmt.Printf("Found %d devices:\n", len(devices))
+	for _, d := range devices {
 		// Show device number, address and module type
-		fmt.Printf("Address: %d module type: %d (0x%x) %s V%d.%d\n", asDevList[dev].I8uAddress,
-			asDevList[dev].I16uModuleType, asDevList[dev].I16uModuleType,
-			mn,
-			asDevList[dev].I16uSW_Major, asDevList[dev].I16uSW_Minor)
+		fmt.Printf("Address: %d module type: %d (0x%x) %s V%s\n", d.Address, d.Info.I16uModuleType, d.Info.I16uModuleType,
+			d.Name, d.FirmwareVersion())
 
-		if asDevList[dev].I8uActive > 0 {
+		switch d.State {
+		case gopicontrol.DeviceActive:
 			fmt.Printf("Module is present\n")
-		} else {
-			if gopicontrol.IsModuleConnected(asDevList[dev].I16uModuleType) {
-				fmt.Printf("Module is NOT present, data is NOT available!!!\n")
-			} else {
-				fmt.Printf("Module is present, but NOT CONFIGURED!!!\n")
-			}
+		case gopicontrol.DeviceNotPresent:
+			fmt.Printf("Module is NOT present, data is NOT available!!!\n")
+		default:
+			fmt.Printf("Module is present, but NOT CONFIGURED!!!\n")
 		}
 
 		// Show offset and length of input section in process image
-		fmt.Printf("     input offset: %d length: %d\n", asDevList[dev].I16uInputOffset,
-			asDevList[dev].I16uInputLength)
+		fmt.Printf("     input offset: %d length: %d\n", d.Input.Offset, d.Input.Length)
 
 		// Show offset and length of output section in process image
-		fmt.Printf("    output offset: %d length: %d\n", asDevList[dev].I16uOutputOffset,
-			asDevList[dev].I16uOutputLength)
+		fmt.Printf("    output offset: %d length: %d\n", d.Output.Offset, d.Output.Length)
 		fmt.Printf("\n")
 	}
 
 	return nil
 }
+
+// setIOCommunication stops, starts or toggles the I/O communication like piTest -S.
+func setIOCommunication(ctrl *gopicontrol.RevPiControl, mode int) (err error) {
+	var stopped bool
+	switch mode {
+	case gopicontrol.IOStop:
+		err = ctrl.StopIO()
+		stopped = ctrl.IOStopped()
+	case gopicontrol.IOStart:
+		err = ctrl.StartIO()
+		stopped = ctrl.IOStopped()
+	case gopicontrol.IOToggle:
+		stopped, err = ctrl.ToggleIO()
+	default:
+		return fmt.Errorf("invalid I/O communication mode %d", mode)
+	}
+	if err != nil {
+		return err
+	}
+
+	if stopped {
+		fmt.Println("I/O communication stopped")
+	} else {
+		fmt.Println("I/O communication running")
+	}
+	return nil
+}
+
+// downloadGatewayConfig downloads the configuration file at path to the gateway on side.
+func downloadGatewayConfig(ctrl *gopicontrol.RevPiControl, path string, side gopicontrol.GatewaySide) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Printf("downloading %s to the %v gateway\n", path, side)
+	if err = ctrl.DownloadGatewayConfig(f, side); err != nil {
+		return err
+	}
+	fmt.Println("gateway configuration downloaded")
+	return nil
+}
+
+// showCoreStatus prints the status of the RevPi Core.
+func showCoreStatus(status gopicontrol.CoreStatus, asJSON bool) (err error) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(status)
+	}
+
+	fmt.Printf("status:         %s\n", status)
+	fmt.Printf("running:        %t\n", status.Running)
+	fmt.Printf("extra module:   %t\n", status.ExtraModule)
+	fmt.Printf("missing module: %t\n", status.MissingModule)
+	fmt.Printf("size mismatch:  %t\n", status.SizeMismatch)
+	fmt.Printf("left gateway:   %t\n", status.LeftGateway)
+	fmt.Printf("right gateway:  %t\n", status.RightGateway)
+	fmt.Printf("X2 DIN:         %t\n", status.X2DIN)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+// runHWWatchdog triggers the hardware watchdog of the RevPi Connect every period while the health checks pass:
+// the modification time of file must be younger than maxAge and command must exit with status 0.
+// Empty checks are skipped. It runs until interrupted or for duration if > 0.
+func runHWWatchdog(ctrl *gopicontrol.RevPiControl, period time.Duration, file string, maxAge time.Duration, command string, duration time.Duration) error {
+	wd := gopicontrol.NewWatchdog(ctrl, period)
+	if file != "" {
+		wd.AddCheck("file "+file, logTransitions("file "+file, func() error {
+			fi, err := os.Stat(file)
+			if err != nil {
+				return err
+			}
+			if age := time.Since(fi.ModTime()); age > maxAge {
+				return fmt.Errorf("not modified for %v", age.Round(time.Millisecond))
+			}
+			return nil
+		}))
+	}
+	if command != "" {
+		wd.AddCheck("command "+command, logTransitions("command "+command, func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), period)
+			defer cancel()
+			return exec.CommandContext(ctx, "sh", "-c", command).Run()
+		}))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	fmt.Printf("triggering the hardware watchdog every %v, the system is reset %v after the last trigger\n",
+		period, gopicontrol.HWWatchdogTimeout)
+	wd.Run(ctx)
+	fmt.Println("stopped triggering the hardware watchdog")
+	return nil
+}
+
+// logTransitions wraps check to print when it starts failing and when it passes again.
+func logTransitions(name string, check gopicontrol.HealthCheck) gopicontrol.HealthCheck {
+	var lastErr error
+	return func() error {
+		err := check()
+		switch {
+		case err != nil && lastErr == nil:
+			fmt.Printf("%s: health check %s failed, not triggering: %v\n", time.Now().Format(time.RFC3339), name, err)
+		case err == nil && lastErr != nil:
+			fmt.Printf("%s: health check %s passes again\n", time.Now().Format(time.RFC3339), name)
+		}
+		lastErr = err
+		return err
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+// parseWithArgs parses fs allowing flags after the positional arguments, which are returned.
+func parseWithArgs(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// runLED sets an LED to a color, or the X2 output on or off, like "led A1 red" or "led X2 on".
+// With blink > 0 the LED blinks until interrupted or for duration if > 0, then it is switched off.
+// Without a color the current state is shown.
+func runLED(ctrl *gopicontrol.RevPiControl, args []string, blink, duration time.Duration) (err error) {
+	leds := gopicontrol.NewLEDs(ctrl)
+	defer leds.Close()
+
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("expected an LED (A1, A2, A3 or X2) and a color (off, green, red, orange or on, off for X2)")
+	}
+
+	if strings.EqualFold(args[0], "X2") {
+		if len(args) == 1 {
+			on, err := leds.X2Out()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("X2 output: %t\n", on)
+			return nil
+		}
+		switch strings.ToLower(args[1]) {
+		case "on", "1":
+			return leds.SetX2Out(true)
+		case "off", "0":
+			return leds.SetX2Out(false)
+		}
+		return fmt.Errorf("invalid X2 output state %q, expected on or off", args[1])
+	}
+
+	led, err := gopicontrol.ParseLED(args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		color, err := leds.Get(led)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("LED %v: %v\n", led, color)
+		return nil
+	}
+	color, err := gopicontrol.ParseLEDColor(args[1])
+	if err != nil {
+		return err
+	}
+	if blink <= 0 {
+		return leds.Set(led, color)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	if err = leds.Blink(led, color, blink); err != nil {
+		return err
+	}
+	fmt.Printf("LED %v blinking %v every %v\n", led, color, blink)
+	<-ctx.Done()
+	return leds.Set(led, gopicontrol.LEDOff)
+}
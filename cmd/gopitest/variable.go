@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"os"
+	"path"
+	"text/tabwriter"
 
 	"github.com/mezzato/revpi/pkg/gopicontrol"
 )
@@ -51,6 +54,54 @@ func writeVariableValue(ctrl *gopicontrol.RevPiControl, variableName string, v u
 	fmt.Printf("written value %d dec (=%02x hex) to offset %d.\n", data, data, sPiVariable.I16uAddress)
 	return nil
 }
+
+// variableFilter selects the variables listed by showVariables.
+type variableFilter struct {
+	address  int    // device address, all if negative
+	typ      string // input, output, memory, config or empty for all
+	pattern  string // name pattern as in path.Match or empty for all
+	exported bool   // only exported variables
+}
+
+func (f *variableFilter) match(v *gopicontrol.Variable) (bool, error) {
+	if f.address >= 0 && int(v.Device.I8uAddress) != f.address {
+		return false, nil
+	}
+	if f.typ != "" && v.Type.String() != f.typ {
+		return false, nil
+	}
+	if f.exported && !v.Exported {
+		return false, nil
+	}
+	if f.pattern != "" {
+		return path.Match(f.pattern, v.Name)
+	}
+	return true, nil
+}
+
+func showVariables(ctrl *gopicontrol.RevPiControl, filter variableFilter) (err error) {
+	vars, err := ctrl.ListVariables()
+	if err != nil {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "name\ttype\texported\toffset\tbit\tlength\tdefault\tdevice")
+	for i := range vars {
+		v := &vars[i]
+		ok, e := filter.match(v)
+		if e != nil {
+			return e
+		}
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%d\t%d\t%d\t%d %s\n", v.Name, v.Type, v.Exported, v.Offset, v.Bit,
+			v.BitLength, v.Default, v.Device.I8uAddress, gopicontrol.GetModuleName(v.Device.I16uModuleType))
+	}
+	return w.Flush()
+}
+
 func showVariableInfo(ctrl *gopicontrol.RevPiControl, variableName string) (err error) {
 	sPiVariable, err := ctrl.GetVariableInfo(variableName)
 	if err != nil {
@@ -128,6 +179,21 @@ func readVariableValue(ctrl *gopicontrol.RevPiControl, variableName string, form
 				} else {
 					fmt.Printf("%x\n", i32uValue)
 				}
+			} else if format == 's' {
+				var signed int32
+				switch sPiVariable.I16uLength {
+				case 8:
+					signed = int32(int8(i32uValue))
+				case 16:
+					signed = int32(int16(i32uValue))
+				case 32:
+					signed = int32(i32uValue)
+				}
+				if !quiet {
+					fmt.Printf("%d byte-value of %s: %d signed dec (=%x hex bytes)\n", size, variableName, signed, data)
+				} else {
+					fmt.Printf("%d\n", signed)
+				}
 			} else if format == 'b' {
 				if !quiet {
 					fmt.Printf("%d byte value of %s: ", size, variableName)
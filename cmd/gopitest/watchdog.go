@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+// runOutputWatchdog activates the output watchdog and feeds it by rewriting variableName every period
+// for duration, then stops feeding and shows the value of the variable after the watchdog expired.
+func runOutputWatchdog(ctrl *gopicontrol.RevPiControl, variableName string, timeout, period, duration time.Duration) (err error) {
+	v, err := ctrl.GetVariableInfo(variableName)
+	if err != nil {
+		return
+	}
+	feed := gopicontrol.Region{Offset: uint32(v.I16uAddress), Length: (uint32(v.I16uLength) + 7) / 8}
+
+	wd := gopicontrol.NewOutputWatchdog(ctrl, timeout, feed)
+	if err = wd.Start(); err != nil {
+		return
+	}
+	fmt.Printf("output watchdog active, timeout: %v, feeding with %s every %v for %v\n", timeout, variableName, period, duration)
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for end := time.Now().Add(duration); time.Now().Before(end); <-ticker.C {
+		if err = wd.Feed(); err != nil {
+			return
+		}
+	}
+
+	fmt.Printf("stopped feeding, waiting %v for the watchdog to expire\n", 2*timeout)
+	time.Sleep(2 * timeout)
+
+	data := make([]byte, feed.Length)
+	if _, err = ctrl.Read(feed.Offset, data); err != nil {
+		return
+	}
+	fmt.Printf("%s after watchdog expiry: %x hex\n", variableName, data)
+
+	return wd.Stop()
+}
@@ -1,3 +1,5 @@
 module github.com/mezzato/revpi
 
+go 1.18
+
 require golang.org/x/sys v0.0.0-20180814072032-4e1fef560951
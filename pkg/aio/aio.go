@@ -0,0 +1,448 @@
+// Package aio accesses the analog inputs, RTD channels and analog outputs of a RevPi AIO module
+// as engineering values: volts, milliamperes and degrees Celsius.
+//
+// The module scales its values with the multiplier, divisor and offset configured in piCtory
+// for each channel, the package reads them from the defaults of the memory variables in config.rsc
+// and undoes the scaling to return the signal measured or generated by the module.
+package aio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+// ModuleType is the module type of the RevPi AIO.
+const ModuleType = 103
+
+// Number of channels of a RevPi AIO.
+const (
+	Inputs  = 4
+	RTDs    = 2
+	Outputs = 2
+)
+
+// Unit is the unit of an engineering value.
+type Unit int
+
+// Units of the AIO signals.
+const (
+	Volt Unit = iota
+	MilliAmpere
+	Celsius
+)
+
+func (u Unit) String() string {
+	switch u {
+	case Volt:
+		return "V"
+	case MilliAmpere:
+		return "mA"
+	case Celsius:
+		return "°C"
+	default:
+		return fmt.Sprintf("Unit(%d)", int(u))
+	}
+}
+
+// Range is the range of the signal of a channel.
+type Range struct {
+	Min, Max float64
+	Unit     Unit
+}
+
+// Contains reports whether v is within the range.
+func (r Range) Contains(v float64) bool {
+	return v >= r.Min && v <= r.Max
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("%g..%g %s", r.Min, r.Max, r.Unit)
+}
+
+// InputRange is the range of an analog input as configured in piCtory (InputNRange).
+type InputRange uint8
+
+// Input ranges.
+const (
+	InputPlusMinus10V  InputRange = 1
+	Input0To10V        InputRange = 2
+	Input0To5V         InputRange = 3
+	InputPlusMinus5V   InputRange = 4
+	Input0To20mA       InputRange = 5
+	Input0To24mA       InputRange = 6
+	Input4To20mA       InputRange = 7
+	InputPlusMinus25mA InputRange = 8
+)
+
+var inputRanges = map[InputRange]Range{
+	InputPlusMinus10V:  {-10, 10, Volt},
+	Input0To10V:        {0, 10, Volt},
+	Input0To5V:         {0, 5, Volt},
+	InputPlusMinus5V:   {-5, 5, Volt},
+	Input0To20mA:       {0, 20, MilliAmpere},
+	Input0To24mA:       {0, 24, MilliAmpere},
+	Input4To20mA:       {4, 20, MilliAmpere},
+	InputPlusMinus25mA: {-25, 25, MilliAmpere},
+}
+
+// Range returns the signal range, false if r is unknown.
+func (r InputRange) Range() (Range, bool) {
+	rng, ok := inputRanges[r]
+	return rng, ok
+}
+
+// OutputRange is the range of an analog output as configured in piCtory (OutputNRange).
+type OutputRange uint8
+
+// Output ranges, OutputOff disables the output.
+const (
+	OutputOff           OutputRange = 0
+	Output0To5V         OutputRange = 1
+	Output0To10V        OutputRange = 2
+	OutputPlusMinus5V   OutputRange = 3
+	OutputPlusMinus10V  OutputRange = 4
+	Output0To5_5V       OutputRange = 5
+	Output0To11V        OutputRange = 6
+	OutputPlusMinus5_5V OutputRange = 7
+	OutputPlusMinus11V  OutputRange = 8
+	Output4To20mA       OutputRange = 9
+	Output0To20mA       OutputRange = 10
+	Output0To24mA       OutputRange = 11
+)
+
+var outputRanges = map[OutputRange]Range{
+	Output0To5V:         {0, 5, Volt},
+	Output0To10V:        {0, 10, Volt},
+	OutputPlusMinus5V:   {-5, 5, Volt},
+	OutputPlusMinus10V:  {-10, 10, Volt},
+	Output0To5_5V:       {0, 5.5, Volt},
+	Output0To11V:        {0, 11, Volt},
+	OutputPlusMinus5_5V: {-5.5, 5.5, Volt},
+	OutputPlusMinus11V:  {-11, 11, Volt},
+	Output4To20mA:       {4, 20, MilliAmpere},
+	Output0To20mA:       {0, 20, MilliAmpere},
+	Output0To24mA:       {0, 24, MilliAmpere},
+}
+
+// Range returns the signal range, false if r is OutputOff or unknown.
+func (r OutputRange) Range() (Range, bool) {
+	rng, ok := outputRanges[r]
+	return rng, ok
+}
+
+// RTDType is the sensor type of an RTD channel as configured in piCtory (RTDNType).
+type RTDType uint8
+
+// RTD sensor types.
+const (
+	PT100  RTDType = 0
+	PT1000 RTDType = 1
+)
+
+func (t RTDType) String() string {
+	switch t {
+	case PT100:
+		return "PT100"
+	case PT1000:
+		return "PT1000"
+	default:
+		return fmt.Sprintf("RTDType(%d)", uint8(t))
+	}
+}
+
+// Scaling is the multiplier, divisor and offset of a channel: the module computes
+// value*Multiplier/Divisor + Offset from the measured value for inputs and RTDs,
+// and the generated value from the process image value for outputs.
+type Scaling struct {
+	Multiplier, Divisor, Offset int16
+}
+
+// Apply returns v*Multiplier/Divisor + Offset.
+func (s Scaling) Apply(v float64) float64 {
+	return v*float64(s.Multiplier)/float64(s.Divisor) + float64(s.Offset)
+}
+
+// Invert returns the value which Apply maps to v.
+func (s Scaling) Invert(v float64) float64 {
+	return (v - float64(s.Offset)) * float64(s.Divisor) / float64(s.Multiplier)
+}
+
+func (s Scaling) valid() bool {
+	return s.Multiplier != 0 && s.Divisor != 0
+}
+
+// InputConfig is the configuration of an analog input.
+type InputConfig struct {
+	Range   InputRange
+	Scaling Scaling
+}
+
+// RTDConfig is the configuration of an RTD channel.
+type RTDConfig struct {
+	Type    RTDType
+	Scaling Scaling
+}
+
+// OutputConfig is the configuration of an analog output.
+type OutputConfig struct {
+	Range   OutputRange
+	Scaling Scaling
+}
+
+// Config is the piCtory configuration of the channels of a module.
+type Config struct {
+	Inputs  [Inputs]InputConfig
+	RTDs    [RTDs]RTDConfig
+	Outputs [Outputs]OutputConfig
+}
+
+// Reading is the value of a channel.
+type Reading struct {
+	Raw    int16   // signed value in the process image, scaled by the module
+	Value  float64 // signal in Unit, computed from Raw and the scaling of the channel
+	Unit   Unit
+	Status uint8 // status of the channel reported by the module, 0 if ok
+}
+
+func (r Reading) String() string {
+	return fmt.Sprintf("%.3f %s (raw %d, status %#02x)", r.Value, r.Unit, r.Raw, r.Status)
+}
+
+// channel is the value and status variables of a channel.
+type channel struct {
+	value  *gopicontrol.Var[int16]
+	status *gopicontrol.Var[uint8]
+}
+
+func (ch *channel) read() (raw int16, status uint8, err error) {
+	if raw, err = ch.value.Get(); err != nil {
+		return
+	}
+	status, err = ch.status.Get()
+	return
+}
+
+// Module is a RevPi AIO module.
+type Module struct {
+	device  gopicontrol.Device
+	config  Config
+	inputs  [Inputs]channel
+	rtds    [RTDs]channel
+	outputs [Outputs]channel
+}
+
+// New resolves the variables of the AIO module at address and reads the channel configuration.
+// Variables are matched by their piCtory name, e.g. InputValue_1 or Input1Multiplier,
+// optionally followed by the suffix piCtory adds to make the names of several modules unique.
+func New(c *gopicontrol.RevPiControl, address uint8) (*Module, error) {
+	device, err := c.DeviceByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if device.ModuleType != ModuleType {
+		return nil, fmt.Errorf("%s is not a RevPi AIO", device)
+	}
+	vars, err := c.VariablesOfDevice(address)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{device: device}
+	r := resolver{ctrl: c, vars: vars}
+	for i := 0; i < Inputs; i++ {
+		n := i + 1
+		m.inputs[i] = r.channel(fmt.Sprintf("InputValue_%d", n), fmt.Sprintf("InputStatus_%d", n))
+		m.config.Inputs[i] = InputConfig{
+			Range:   InputRange(r.memory(fmt.Sprintf("Input%dRange", n))),
+			Scaling: r.scaling(fmt.Sprintf("Input%d", n)),
+		}
+	}
+	for i := 0; i < RTDs; i++ {
+		n := i + 1
+		m.rtds[i] = r.channel(fmt.Sprintf("RTDValue_%d", n), fmt.Sprintf("RTDStatus_%d", n))
+		m.config.RTDs[i] = RTDConfig{
+			Type:    RTDType(r.memory(fmt.Sprintf("RTD%dType", n))),
+			Scaling: r.scaling(fmt.Sprintf("RTD%d", n)),
+		}
+	}
+	for i := 0; i < Outputs; i++ {
+		n := i + 1
+		m.outputs[i] = r.channel(fmt.Sprintf("OutputValue_%d", n), fmt.Sprintf("OutputStatus_%d", n))
+		m.config.Outputs[i] = OutputConfig{
+			Range:   OutputRange(r.memory(fmt.Sprintf("Output%dRange", n))),
+			Scaling: r.scaling(fmt.Sprintf("Output%d", n)),
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %w", device, r.err)
+	}
+	return m, nil
+}
+
+// Modules returns all AIO modules of the system.
+func Modules(c *gopicontrol.RevPiControl) ([]*Module, error) {
+	devices, err := c.DevicesByType(ModuleType)
+	if err != nil {
+		return nil, err
+	}
+	modules := make([]*Module, 0, len(devices))
+	for _, d := range devices {
+		m, err := New(c, d.Address)
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
+
+// Device returns the description of the module.
+func (m *Module) Device() gopicontrol.Device {
+	return m.device
+}
+
+// Config returns the channel configuration read when the module was resolved.
+func (m *Module) Config() Config {
+	return m.config
+}
+
+// Input reads the analog input n (1-4) in volts or milliamperes, depending on its range.
+// The value is the electrical signal measured by the module, not the user-scaled value that the module
+// writes into InputValue_n with the configured multiplier, divisor and offset: that one is Reading.Raw.
+func (m *Module) Input(n int) (Reading, error) {
+	if n < 1 || n > Inputs {
+		return Reading{}, fmt.Errorf("invalid analog input %d", n)
+	}
+	cfg := m.config.Inputs[n-1]
+	rng, ok := cfg.Range.Range()
+	if !ok {
+		return Reading{}, fmt.Errorf("analog input %d has an unknown range %d", n, cfg.Range)
+	}
+	raw, status, err := m.inputs[n-1].read()
+	if err != nil {
+		return Reading{}, err
+	}
+	// the module measures millivolts or microamperes
+	return Reading{Raw: raw, Value: cfg.Scaling.Invert(float64(raw)) / 1000, Unit: rng.Unit, Status: status}, nil
+}
+
+// RTD reads the temperature of the RTD channel n (1-2) in degrees Celsius.
+func (m *Module) RTD(n int) (Reading, error) {
+	if n < 1 || n > RTDs {
+		return Reading{}, fmt.Errorf("invalid RTD channel %d", n)
+	}
+	raw, status, err := m.rtds[n-1].read()
+	if err != nil {
+		return Reading{}, err
+	}
+	// the module measures tenths of degree
+	return Reading{Raw: raw, Value: m.config.RTDs[n-1].Scaling.Invert(float64(raw)) / 10, Unit: Celsius, Status: status}, nil
+}
+
+// Output reads back the analog output n (1-2) in volts or milliamperes, depending on its range.
+func (m *Module) Output(n int) (Reading, error) {
+	rng, err := m.outputRange(n)
+	if err != nil {
+		return Reading{}, err
+	}
+	raw, status, err := m.outputs[n-1].read()
+	if err != nil {
+		return Reading{}, err
+	}
+	return Reading{Raw: raw, Value: m.config.Outputs[n-1].Scaling.Apply(float64(raw)) / 1000, Unit: rng.Unit, Status: status}, nil
+}
+
+// SetOutput sets the analog output n (1-2) to v volts or milliamperes, depending on its range.
+// An error is returned if v is outside of the configured range or the output is off.
+func (m *Module) SetOutput(n int, v float64) error {
+	rng, err := m.outputRange(n)
+	if err != nil {
+		return err
+	}
+	if !rng.Contains(v) {
+		return fmt.Errorf("value %g %s out of the range %s of analog output %d", v, rng.Unit, rng, n)
+	}
+	// the module generates millivolts or microamperes
+	raw := math.Round(m.config.Outputs[n-1].Scaling.Invert(v * 1000))
+	if raw < math.MinInt16 || raw > math.MaxInt16 {
+		return fmt.Errorf("value %g %s of analog output %d exceeds 16 bits with the configured scaling", v, rng.Unit, n)
+	}
+	return m.outputs[n-1].value.Set(int16(raw))
+}
+
+// SetOutputRaw writes the process image value of the analog output n (1-2), before the module scaling.
+func (m *Module) SetOutputRaw(n int, raw int16) error {
+	if n < 1 || n > Outputs {
+		return fmt.Errorf("invalid analog output %d", n)
+	}
+	return m.outputs[n-1].value.Set(raw)
+}
+
+func (m *Module) outputRange(n int) (Range, error) {
+	if n < 1 || n > Outputs {
+		return Range{}, fmt.Errorf("invalid analog output %d", n)
+	}
+	r := m.config.Outputs[n-1].Range
+	if r == OutputOff {
+		return Range{}, fmt.Errorf("analog output %d is off", n)
+	}
+	rng, ok := r.Range()
+	if !ok {
+		return Range{}, fmt.Errorf("analog output %d has an unknown range %d", n, r)
+	}
+	return rng, nil
+}
+
+// resolver looks up the variables of a module by their piCtory name, keeping the first error.
+type resolver struct {
+	ctrl *gopicontrol.RevPiControl
+	vars []gopicontrol.Variable
+	err  error
+}
+
+// lookup returns the variable named base, or base followed by "_" and a suffix.
+func (r *resolver) lookup(base string) *gopicontrol.Variable {
+	if r.err != nil {
+		return nil
+	}
+	for i := range r.vars {
+		if name := r.vars[i].Name; name == base || strings.HasPrefix(name, base+"_") {
+			return &r.vars[i]
+		}
+	}
+	r.err = fmt.Errorf("%w %s", gopicontrol.ErrVariableNotFound, base)
+	return nil
+}
+
+func (r *resolver) channel(value, status string) (ch channel) {
+	if v := r.lookup(value); v != nil {
+		ch.value, r.err = gopicontrol.Int16(r.ctrl, v.Name)
+	}
+	if v := r.lookup(status); v != nil {
+		ch.status, r.err = gopicontrol.Uint8(r.ctrl, v.Name)
+	}
+	return ch
+}
+
+// memory returns the default value of a memory variable, the value configured in piCtory.
+func (r *resolver) memory(base string) uint32 {
+	if v := r.lookup(base); v != nil {
+		return v.Default
+	}
+	return 0
+}
+
+func (r *resolver) scaling(prefix string) Scaling {
+	s := Scaling{
+		Multiplier: int16(r.memory(prefix + "Multiplier")),
+		Divisor:    int16(r.memory(prefix + "Divisor")),
+		Offset:     int16(r.memory(prefix + "Offset")),
+	}
+	if r.err == nil && !s.valid() {
+		r.err = fmt.Errorf("invalid scaling of %s: multiplier %d, divisor %d", prefix, s.Multiplier, s.Divisor)
+	}
+	return s
+}
@@ -0,0 +1,238 @@
+package aio
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+	"github.com/mezzato/revpi/pkg/pisim"
+)
+
+// testMemory is the channel configuration of the test module:
+// input 1 ±10 V scaled by 3/2 + 100, input 2 4-20 mA unscaled,
+// RTD 1 PT100 with an offset of -50, output 1 ±10 V scaled by 2 - 1000, output 2 0-10 V scaled by 1/10.
+var testMemory = map[string]int{
+	"Input1Range": int(InputPlusMinus10V), "Input1Multiplier": 3, "Input1Divisor": 2, "Input1Offset": 100,
+	"Input2Range": int(Input4To20mA),
+	"Input3Range": int(Input0To10V),
+	"Input4Range": int(Input0To10V),
+	"RTD1Type":    int(PT100), "RTD1Offset": -50,
+	"RTD2Type":     int(PT1000),
+	"Output1Range": int(OutputPlusMinus10V), "Output1Multiplier": 2, "Output1Offset": -1000,
+	"Output2Range": int(Output0To10V), "Output2Divisor": 10,
+}
+
+// testConfig returns a config.rsc with a RevPi Core and an AIO at address 32 configured with memory,
+// multipliers and divisors default to 1 and offsets to 0.
+func testConfig(memory map[string]int) string {
+	var inp, out, mem []string
+	entry := func(section *[]string, name string, def, bits, offset int) {
+		*section = append(*section, fmt.Sprintf(`"%d": ["%s", "%d", "%d", "%d", false, "%04d", "", ""]`,
+			len(*section), name, def, bits, offset, len(*section)))
+	}
+	for n := 1; n <= Inputs; n++ {
+		entry(&inp, fmt.Sprintf("InputValue_%d", n), 0, 16, 2*(n-1))
+		entry(&inp, fmt.Sprintf("InputStatus_%d", n), 0, 8, 8+n-1)
+	}
+	for n := 1; n <= RTDs; n++ {
+		entry(&inp, fmt.Sprintf("RTDValue_%d", n), 0, 16, 12+2*(n-1))
+		entry(&inp, fmt.Sprintf("RTDStatus_%d", n), 0, 8, 16+n-1)
+	}
+	for n := 1; n <= Outputs; n++ {
+		entry(&inp, fmt.Sprintf("OutputStatus_%d", n), 0, 8, 18+n-1)
+		entry(&out, fmt.Sprintf("OutputValue_%d", n), 0, 16, 2*(n-1))
+	}
+
+	offset := 0
+	memory16 := func(name string, def int) {
+		if v, ok := memory[name]; ok {
+			def = v
+		}
+		entry(&mem, name, def, 16, offset)
+		offset += 2
+	}
+	for _, channel := range []struct {
+		prefix, kind string
+		count        int
+	}{{"Input", "Range", Inputs}, {"RTD", "Type", RTDs}, {"Output", "Range", Outputs}} {
+		for n := 1; n <= channel.count; n++ {
+			prefix := fmt.Sprintf("%s%d", channel.prefix, n)
+			memory16(prefix+channel.kind, 0)
+			memory16(prefix+"Multiplier", 1)
+			memory16(prefix+"Divisor", 1)
+			memory16(prefix+"Offset", 0)
+		}
+	}
+
+	return `{
+ "App": {"name": "PiCtory", "version": "1.4.0"},
+ "Summary": {"inpTotal": 21, "outTotal": 4},
+ "Devices": [
+  {
+   "GUID": "core", "id": "device_RevPiCore", "type": "BASE", "productType": "95", "position": "0",
+   "name": "RevPi Core", "offset": 0,
+   "inp": {"0": ["RevPiStatus", "0", "8", "0", false, "0000", "", ""]},
+   "out": {},
+   "mem": {}
+  },
+  {
+   "GUID": "aio", "id": "device_RevPiAIO", "type": "RIGHT", "productType": "103", "position": "32",
+   "name": "RevPi AIO", "offset": 1,
+   "inp": {` + strings.Join(inp, ",") + `},
+   "out": {` + strings.Join(out, ",") + `},
+   "mem": {` + strings.Join(mem, ",") + `}
+  }
+ ]
+}`
+}
+
+func newTestModule(t *testing.T, memory map[string]int) (*Module, *pisim.Simulator) {
+	t.Helper()
+	sim, err := pisim.New(strings.NewReader(testConfig(memory)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(gopicontrol.NewRevPiControlWithBackend(sim), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, sim
+}
+
+// setRaw sets a signed 16 bit input of the simulator.
+func setRaw(t *testing.T, sim *pisim.Simulator, name string, raw int16) {
+	t.Helper()
+	if err := sim.SetValue(name, uint32(uint16(raw))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestConfig(t *testing.T) {
+	m, _ := newTestModule(t, testMemory)
+	cfg := m.Config()
+	if want := (InputConfig{InputPlusMinus10V, Scaling{3, 2, 100}}); cfg.Inputs[0] != want {
+		t.Errorf("got input 1 %+v, want %+v", cfg.Inputs[0], want)
+	}
+	if want := (RTDConfig{PT100, Scaling{1, 1, -50}}); cfg.RTDs[0] != want {
+		t.Errorf("got RTD 1 %+v, want %+v", cfg.RTDs[0], want)
+	}
+	if want := (OutputConfig{OutputPlusMinus10V, Scaling{2, 1, -1000}}); cfg.Outputs[0] != want {
+		t.Errorf("got output 1 %+v, want %+v", cfg.Outputs[0], want)
+	}
+}
+
+func TestInputs(t *testing.T) {
+	m, sim := newTestModule(t, testMemory)
+
+	// -4.5 V measured as -4500 mV, scaled by the module to -4500*3/2+100
+	setRaw(t, sim, "InputValue_1", -6650)
+	setRaw(t, sim, "InputValue_2", 12000)
+	for _, tc := range []struct {
+		n     int
+		raw   int16
+		value float64
+		unit  Unit
+	}{
+		{1, -6650, -4.5, Volt},
+		{2, 12000, 12, MilliAmpere},
+	} {
+		r, err := m.Input(tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Raw != tc.raw || !near(r.Value, tc.value) || r.Unit != tc.unit {
+			t.Errorf("input %d: got %v, want %g %s from raw %d", tc.n, r, tc.value, tc.unit, tc.raw)
+		}
+	}
+
+	// -20.5 °C measured as -205 tenths, offset by -50
+	setRaw(t, sim, "RTDValue_1", -255)
+	r, err := m.RTD(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Raw != -255 || !near(r.Value, -20.5) || r.Unit != Celsius {
+		t.Errorf("got RTD 1 %v, want -20.5 °C", r)
+	}
+
+	if _, err = m.Input(5); err == nil {
+		t.Error("reading input 5 succeeded")
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	m, sim := newTestModule(t, testMemory)
+
+	// -3.5 V generated from raw*2-1000 mV
+	if err := m.SetOutput(1, -3.5); err != nil {
+		t.Fatal(err)
+	}
+	v, err := sim.Value("OutputValue_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw := int16(v); raw != -1250 {
+		t.Errorf("got raw %d, want -1250", raw)
+	}
+	r, err := m.Output(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Raw != -1250 || !near(r.Value, -3.5) || r.Unit != Volt {
+		t.Errorf("got output 1 %v, want -3.5 V", r)
+	}
+}
+
+func TestSetOutputErrors(t *testing.T) {
+	m, sim := newTestModule(t, testMemory)
+	for _, tc := range []struct {
+		n int
+		v float64
+	}{
+		{1, 10.5},  // out of the ±10 V range
+		{1, -10.5}, // out of the ±10 V range
+		{2, -1},    // out of the 0-10 V range
+		{2, 5},     // 50000 exceeds int16 with the divisor of 10
+		{3, 0},     // no output 3
+	} {
+		if err := m.SetOutput(tc.n, tc.v); err == nil {
+			t.Errorf("setting output %d to %g succeeded", tc.n, tc.v)
+		}
+	}
+	if err := m.SetOutput(2, 3); err != nil {
+		t.Errorf("setting output 2 to 3 V: %v", err)
+	}
+	if v, _ := sim.Value("OutputValue_2"); v != 30000 {
+		t.Errorf("got raw %d, want 30000", v)
+	}
+
+	off := map[string]int{"Output2Range": int(OutputOff)}
+	for k, v := range testMemory {
+		if _, ok := off[k]; !ok {
+			off[k] = v
+		}
+	}
+	m, _ = newTestModule(t, off)
+	if err := m.SetOutput(2, 0); err == nil {
+		t.Error("setting an output that is off succeeded")
+	}
+}
+
+func TestScalingRoundTrip(t *testing.T) {
+	for _, s := range []Scaling{{1, 1, 0}, {3, 2, 100}, {-7, 3, -250}, {1, 10, 0}, {1000, 1, -32768}} {
+		for _, v := range []float64{-32768, -1234.5, 0, 1, 4500, 32767} {
+			if got := s.Invert(s.Apply(v)); !near(got, v) && math.Abs(got-v) > 1e-9*math.Abs(v) {
+				t.Errorf("%+v: Invert(Apply(%g)) = %g", s, v, got)
+			}
+			if got := s.Apply(s.Invert(v)); !near(got, v) && math.Abs(got-v) > 1e-9*math.Abs(v) {
+				t.Errorf("%+v: Apply(Invert(%g)) = %g", s, v, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package gopicontrol
+
+// Backend is the access layer to a piControl process image used by RevPiControl.
+// DeviceBackend talks to the piControl kernel driver, other implementations can be used
+// to run applications without a RevPi, e.g. a simulator or a fake in unit tests.
+//
+// All methods take the raw piControl structures, a backend is expected to behave like the
+// corresponding ioctl calls of the kernel driver and to report failures as *DriverError,
+// or as errors wrapping ErrVariableNotFound for unknown variable names.
+type Backend interface {
+	// Open prepares the backend for use, it is called lazily by the other methods if needed.
+	Open() error
+	// Close releases the backend resources.
+	Close() error
+	// Reset resets the driver and reloads the configuration.
+	Reset() error
+	// Read reads len(pData) bytes of the process image starting at offset.
+	Read(offset uint32, pData []byte) (n int, err error)
+	// Write writes len(pData) bytes to the process image starting at offset.
+	Write(offset uint32, pData []byte) (n int, err error)
+	// GetDeviceInfo fills the description of the device with the address set in devInfo.
+	GetDeviceInfo(devInfo *SDeviceInfo) (result int, err error)
+	// GetDeviceInfoList returns the description of all configured and detected devices.
+	GetDeviceInfoList() ([]SDeviceInfo, error)
+	// GetEntryInfoList returns the table of all entries, numbered as in SDeviceInfo.I16uFirstEntry.
+	// The driver has no call for it: DeviceBackend parses the piCtory config.rsc on every call, so
+	// RevPiControl loads the table once and reloads it after a reset, see RevPiControl.ListVariables.
+	GetEntryInfoList() ([]SEntryInfo, error)
+	// GetBitValue reads the bit (or byte if I8uBit >= 8) addressed by pSpiValue.
+	GetBitValue(pSpiValue *SPIValue) error
+	// SetBitValue writes the bit (or byte if I8uBit >= 8) addressed by pSpiValue.
+	SetBitValue(pSpiValue *SPIValue) error
+	// GetVariableInfo looks up a piCtory variable by name.
+	GetVariableInfo(name string) (*SPIVariable, error)
+	// SetExportedOutputs copies the exported outputs from image, a whole process image, to the process image.
+	SetExportedOutputs(image []byte) error
+	// ResetCounter resets the counters/encoders selected by bitfield on a DIO/DI module.
+	ResetCounter(address uint8, bitfield uint16) (result int, err error)
+	// SetOutputWatchdog activates the output watchdog of the handle with a timeout in milliseconds, 0 deactivates it.
+	SetOutputWatchdog(timeoutMs uint32) error
+	// StopIO stops, starts or toggles the I/O communication with the modules (IOStop, IOStart, IOToggle)
+	// and returns whether it is stopped afterwards.
+	StopIO(mode int) (stopped bool, err error)
+	// ConfigStop stops the I/O communication to download a configuration to a master gateway.
+	ConfigStop() error
+	// ConfigSend sends a chunk of the gateway configuration.
+	ConfigSend(data *SConfigData) error
+	// ConfigStart restarts the I/O communication after a configuration download.
+	ConfigStart() error
+	// WaitForEvent blocks until the driver signals an event and returns its code.
+	WaitForEvent() (event int, err error)
+	// LastMessage returns the last message of the driver, e.g. the result of a firmware update.
+	LastMessage() (string, error)
+	// UpdateFirmware updates the firmware of the module at addrP, 0 selects the module automatically.
+	UpdateFirmware(addrP uint32) (result int, err error)
+}
@@ -0,0 +1,348 @@
+package gopicontrol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// BindTag is the struct tag used by ReadInto and WriteFrom, e.g.:
+//
+//	type Machine struct {
+//		Start   bool    `revpi:"I_1"`
+//		Speed   int16   `revpi:"AnalogIn"`
+//		Level   float64 `revpi:"InputValue_1,signed"`
+//		Motor   bool    `revpi:"O_1,output"`
+//		Setting uint16  `revpi:"AnalogOut,output"`
+//	}
+//
+// The tag value is the variable name followed by options:
+// "output" marks the fields written by WriteFrom and is rejected on variables that are not outputs,
+// "signed" reads the variable as signed into integer and float fields and is rejected on unsigned fields.
+// Signed integer fields with the size of the variable are signed without the option, wider ones hold
+// the unsigned value unless "signed" is given.
+// Untagged struct fields are bound recursively, other untagged fields are ignored.
+const BindTag = "revpi"
+
+// fieldBinding is a struct field bound to a process image variable.
+type fieldBinding struct {
+	index  []int
+	name   string
+	offset uint32
+	bit    uint8
+	bits   uint16
+	output bool
+	signed bool
+}
+
+func (f *fieldBinding) size() uint32 {
+	if f.bits == 1 {
+		return 1
+	}
+	return uint32(f.bits / 8)
+}
+
+// byteRange is a contiguous region of the process image.
+type byteRange struct {
+	offset, length uint32
+}
+
+// structBinding holds the resolved fields of a struct type and the regions to read and write.
+type structBinding struct {
+	fields      []fieldBinding
+	readRanges  []byteRange
+	writeRanges []byteRange // regions of the whole byte outputs, bit outputs are written one by one
+}
+
+// ReadInto reads the variables bound to the fields of the struct pointed to by v in one pass over the process image.
+// The variables are resolved on the first call for each struct type, see BindTag.
+func (c *RevPiControl) ReadInto(v interface{}) error {
+	rv, b, err := c.structBinding(v)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range b.readRanges {
+		data := make([]byte, r.length)
+		n, err := c.Read(r.offset, data)
+		if err != nil {
+			return err
+		}
+		if n != len(data) {
+			return fmt.Errorf("could not read %d bytes at offset %d: %d bytes read", r.length, r.offset, n)
+		}
+		for i := range b.fields {
+			f := &b.fields[i]
+			if f.offset < r.offset || f.offset+f.size() > r.offset+r.length {
+				continue
+			}
+			var raw uint32
+			if f.bits == 1 {
+				raw = uint32(data[f.offset-r.offset]>>f.bit) & 1
+			} else {
+				raw = leUint32(data[f.offset-r.offset : f.offset-r.offset+f.size()])
+			}
+			f.set(rv.FieldByIndex(f.index), raw)
+		}
+	}
+	return nil
+}
+
+// WriteFrom writes the fields of the struct pointed to by v tagged as "output" to the process image.
+// Whole byte variables are written with as few writes as possible, bits one by one.
+func (c *RevPiControl) WriteFrom(v interface{}) error {
+	rv, b, err := c.structBinding(v)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range b.writeRanges {
+		data := make([]byte, r.length)
+		for i := range b.fields {
+			f := &b.fields[i]
+			if !f.output || f.bits == 1 || f.offset < r.offset || f.offset+f.size() > r.offset+r.length {
+				continue
+			}
+			raw, err := f.get(rv.FieldByIndex(f.index))
+			if err != nil {
+				return err
+			}
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], raw)
+			copy(data[f.offset-r.offset:], buf[:f.size()])
+		}
+		n, err := c.Write(r.offset, data)
+		if err != nil {
+			return err
+		}
+		if n != len(data) {
+			return fmt.Errorf("could not write %d bytes at offset %d: %d bytes written", r.length, r.offset, n)
+		}
+	}
+
+	for i := range b.fields {
+		f := &b.fields[i]
+		if !f.output || f.bits != 1 {
+			continue
+		}
+		raw, err := f.get(rv.FieldByIndex(f.index))
+		if err != nil {
+			return err
+		}
+		val := SPIValue{I16uAddress: uint16(f.offset), I8uBit: f.bit, I8uValue: uint8(raw)}
+		if err = c.SetBitValue(&val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// structBinding returns the binding of the struct pointed to by v, resolving it on first use.
+func (c *RevPiControl) structBinding(v interface{}) (reflect.Value, *structBinding, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("expected a non-nil pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+
+	c.bindMu.Lock()
+	defer c.bindMu.Unlock()
+	if b, ok := c.bindings[rv.Type()]; ok {
+		return rv, b, nil
+	}
+
+	b := &structBinding{}
+	if err := c.bindFields(b, rv.Type(), nil); err != nil {
+		return reflect.Value{}, nil, err
+	}
+	b.readRanges = mergeRanges(b.fields, func(f *fieldBinding) bool { return true })
+	b.writeRanges = mergeRanges(b.fields, func(f *fieldBinding) bool { return f.output && f.bits != 1 })
+
+	if c.bindings == nil {
+		c.bindings = map[reflect.Type]*structBinding{}
+	}
+	c.bindings[rv.Type()] = b
+	return rv, b, nil
+}
+
+// bindFields resolves the tagged fields of t, descending into untagged struct fields.
+func (c *RevPiControl) bindFields(b *structBinding, t reflect.Type, index []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+		tag, ok := sf.Tag.Lookup(BindTag)
+		if !ok {
+			if sf.Type.Kind() == reflect.Struct {
+				if err := c.bindFields(b, sf.Type, fieldIndex); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return fmt.Errorf("field %s: cannot bind an unexported field", sf.Name)
+		}
+
+		opts := strings.Split(tag, ",")
+		f := fieldBinding{index: fieldIndex, name: opts[0]}
+		for _, o := range opts[1:] {
+			switch o {
+			case "output":
+				f.output = true
+			case "signed":
+				f.signed = true
+			default:
+				return fmt.Errorf("field %s: unknown option %q", sf.Name, o)
+			}
+		}
+
+		v, err := c.GetVariableInfo(f.name)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		f.offset = uint32(v.I16uAddress) + uint32(v.I8uBit/8)
+		f.bit = v.I8uBit % 8
+		f.bits = v.I16uLength
+		if f.output {
+			if err = c.checkOutput(f.name); err != nil {
+				return fmt.Errorf("field %s: %w", sf.Name, err)
+			}
+		}
+
+		if err = checkFieldType(sf.Type, &f); err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		b.fields = append(b.fields, f)
+	}
+	return nil
+}
+
+// checkOutput checks that the variable name is an output.
+func (c *RevPiControl) checkOutput(name string) error {
+	vars, err := c.variables()
+	if err != nil {
+		return err
+	}
+	v, ok := vars.byName[name]
+	if !ok {
+		return variableNotFound(name)
+	}
+	if v.Type != EntryOutput {
+		return fmt.Errorf("variable %s is of type %s and cannot be bound as output", name, v.Type)
+	}
+	return nil
+}
+
+// checkFieldType checks that a field of type t can hold the variable bound to f.
+func checkFieldType(t reflect.Type, f *fieldBinding) error {
+	switch f.bits {
+	case 1, 8, 16, 32:
+	default:
+		return fmt.Errorf("variable %s has an unsupported length of %d bits", f.name, f.bits)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if f.bits != 1 {
+			return fmt.Errorf("variable %s has %d bits and cannot be bound to a bool", f.name, f.bits)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.signed = f.bits > 1 && (f.signed || t.Bits() == int(f.bits))
+		if t.Bits() < int(f.bits) {
+			return fmt.Errorf("variable %s has %d bits and cannot be bound to %s", f.name, f.bits, t)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f.signed {
+			return fmt.Errorf("variable %s cannot be read as signed into %s", f.name, t)
+		}
+		if t.Bits() < int(f.bits) {
+			return fmt.Errorf("variable %s has %d bits and cannot be bound to %s", f.name, f.bits, t)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f.bits == 1 {
+			f.signed = false
+		}
+	default:
+		return fmt.Errorf("variable %s cannot be bound to %s", f.name, t)
+	}
+	return nil
+}
+
+// set stores the raw variable value into the field v.
+func (f *fieldBinding) set(v reflect.Value, raw uint32) {
+	signed := int64(raw)
+	if f.signed && f.bits > 1 {
+		shift := 64 - uint(f.bits)
+		signed = int64(raw) << shift >> shift
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(raw != 0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(signed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(raw))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(signed))
+	}
+}
+
+// get returns the raw variable value from the field v, checking that it fits the variable.
+func (f *fieldBinding) get(v reflect.Value) (uint32, error) {
+	var n int64
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d out of range for variable %s", v.Uint(), f.name)
+		}
+		n = int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = int64(math.Round(v.Float()))
+	}
+
+	min, max := int64(0), int64(1)<<f.bits-1
+	if f.signed {
+		min, max = -(int64(1) << (f.bits - 1)), int64(1)<<(f.bits-1)-1
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d out of range for variable %s", n, f.name)
+	}
+	return uint32(n), nil
+}
+
+// mergeRanges returns the sorted contiguous regions covering the selected fields.
+func mergeRanges(fields []fieldBinding, selected func(f *fieldBinding) bool) []byteRange {
+	var ranges []byteRange
+	for i := range fields {
+		if selected(&fields[i]) {
+			ranges = append(ranges, byteRange{fields[i].offset, fields[i].size()})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].offset < ranges[j].offset })
+
+	var merged []byteRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.offset <= merged[n-1].offset+merged[n-1].length {
+			if end := r.offset + r.length; end > merged[n-1].offset+merged[n-1].length {
+				merged[n-1].length = end - merged[n-1].offset
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
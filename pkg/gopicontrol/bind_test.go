@@ -0,0 +1,150 @@
+package gopicontrol_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestBindSignedUnsignedField(t *testing.T) {
+	c, _ := newSimControl(t)
+	var s struct {
+		Status uint16 `revpi:"InputStatus,signed"`
+	}
+	if err := c.ReadInto(&s); err == nil {
+		t.Fatal("got no error binding a signed variable to a uint16 field")
+	}
+}
+
+func TestBindRoundTrip(t *testing.T) {
+	c, b := newSimControl(t)
+	type machine struct {
+		Motor bool    `revpi:"O_1,output"`
+		Value int16   `revpi:"Value,output"`
+		Level float64 `revpi:"InputStatus,signed"`
+	}
+	setValue(t, b, "InputStatus", 0xfffe)
+	if err := c.WriteFrom(&machine{Motor: true, Value: -5}); err != nil {
+		t.Fatal(err)
+	}
+	var m machine
+	if err := c.ReadInto(&m); err != nil {
+		t.Fatal(err)
+	}
+	if want := (machine{Motor: true, Value: -5, Level: -2}); m != want {
+		t.Errorf("got %+v, want %+v", m, want)
+	}
+}
+
+func TestBindWideSignedField(t *testing.T) {
+	c, b := newSimControl(t)
+	setValue(t, b, "Counter_1", 0x80000000)
+	var s struct {
+		Wide   int64 `revpi:"Counter_1"`
+		Signed int64 `revpi:"Counter_1,signed"`
+		Exact  int32 `revpi:"Counter_1"`
+	}
+	if err := c.ReadInto(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Wide != 0x80000000 {
+		t.Errorf("got int64 %d, want %d", s.Wide, int64(0x80000000))
+	}
+	if s.Signed != -0x80000000 || s.Exact != -0x80000000 {
+		t.Errorf("got signed int64 %d and int32 %d, want %d", s.Signed, s.Exact, -0x80000000)
+	}
+}
+
+func TestBindReadRanges(t *testing.T) {
+	c, b := newSimControl(t)
+	var s struct {
+		Status  uint8  `revpi:"RevPiStatus"`
+		LED     uint8  `revpi:"RevPiLED"`
+		In1     bool   `revpi:"I_1"`
+		In2     bool   `revpi:"I_2"`
+		Inputs  uint16 `revpi:"InputStatus"`
+		Counter uint32 `revpi:"Counter_2"`
+	}
+	for name, v := range map[string]uint32{"RevPiLED": 3, "I_2": 1, "InputStatus": 0x1234, "Counter_2": 70000} {
+		setValue(t, b, name, v)
+	}
+	b.counts()
+
+	if err := c.ReadInto(&s); err != nil {
+		t.Fatal(err)
+	}
+	// offsets 0-2, 4-5 and 12-15
+	if reads, _, _ := b.counts(); reads != 3 {
+		t.Errorf("got %d reads, want 3", reads)
+	}
+	if s.LED != 3 || s.In1 || !s.In2 || s.Inputs != 0x1234 || s.Counter != 70000 {
+		t.Errorf("got %+v", s)
+	}
+}
+
+func TestBindWriteBits(t *testing.T) {
+	c, b := newSimControl(t)
+	setValue(t, b, "O_3", 1)
+	b.counts()
+
+	s := struct {
+		Motor bool   `revpi:"O_1,output"`
+		Pump  bool   `revpi:"O_2,output"`
+		Duty  uint8  `revpi:"PWM_1,output"`
+		Value uint16 `revpi:"Value,output"`
+	}{Pump: true, Duty: 40, Value: 1000}
+	if err := c.WriteFrom(&s); err != nil {
+		t.Fatal(err)
+	}
+	if _, writes, setBit := b.counts(); writes != 1 || setBit != 2 {
+		t.Errorf("got %d writes and %d bit sets, want 1 and 2", writes, setBit)
+	}
+	for name, want := range map[string]uint32{"O_1": 0, "O_2": 1, "O_3": 1, "PWM_1": 40, "Value": 1000, "Spare": 0} {
+		if got := value(t, b, name); got != want {
+			t.Errorf("got %s %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	c, _ := newSimControl(t)
+	var unexported struct {
+		in bool `revpi:"I_1"`
+	}
+	for name, v := range map[string]interface{}{
+		"not a pointer": struct{}{},
+		"nil pointer":   (*struct{})(nil),
+		"input as output": &struct {
+			In bool `revpi:"I_1,output"`
+		}{},
+		"unknown option": &struct {
+			In bool `revpi:"I_1,inverted"`
+		}{},
+		"bool on 16 bit": &struct {
+			In bool `revpi:"InputStatus"`
+		}{},
+		"int8 on 16 bit": &struct {
+			In int8 `revpi:"InputStatus"`
+		}{},
+		"unexported field": &unexported,
+	} {
+		if err := c.ReadInto(v); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+
+	var missing struct {
+		In bool `revpi:"Missing"`
+	}
+	if err := c.ReadInto(&missing); !errors.Is(err, gopicontrol.ErrVariableNotFound) {
+		t.Errorf("got %v, want ErrVariableNotFound", err)
+	}
+
+	out := struct {
+		Duty uint16 `revpi:"PWM_1,output"`
+	}{Duty: 300}
+	if err := c.WriteFrom(&out); err == nil {
+		t.Error("writing 300 to an 8 bit variable succeeded")
+	}
+}
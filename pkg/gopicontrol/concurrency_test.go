@@ -0,0 +1,105 @@
+package gopicontrol
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentAccess drives the RevPiControl API from several goroutines, run it with go test -race.
+func TestConcurrentAccess(t *testing.T) {
+	fake := newFakeBackend()
+	c := NewRevPiControlWithBackend(fake)
+	defer c.Close()
+
+	const workers, iterations = 8, 200
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*5)
+	run := func(f func(i int) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := f(i); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	for w := 0; w < workers; w++ {
+		w := w
+		run(func(i int) error {
+			_, err := c.Write(16+uint32(w), []byte{byte(i)})
+			return err
+		})
+		run(func(i int) error {
+			var b [4]byte
+			_, err := c.Read(16, b[:])
+			return err
+		})
+		run(func(i int) error {
+			_, err := c.GetVariableInfo([]string{"I_1", "Status", "O_1", "Value"}[i%4])
+			return err
+		})
+		run(func(i int) error {
+			_, err := c.Snapshot()
+			return err
+		})
+		run(func(i int) error {
+			tx := c.Begin()
+			if err := tx.SetBool("O_1", i%2 == 0); err != nil {
+				return err
+			}
+			if err := tx.SetUint32("Value", uint32(i)); err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+	}
+	run(func(i int) error {
+		if i%20 != 0 {
+			return nil
+		}
+		return c.Reset()
+	})
+	run(func(i int) error {
+		if i%50 != 0 {
+			return nil
+		}
+		return c.Close()
+	})
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	var b [1]byte
+	for w := 0; w < workers; w++ {
+		if _, err := c.Read(16+uint32(w), b[:]); err != nil {
+			t.Fatal(err)
+		}
+		if b[0] != byte(iterations-1) {
+			t.Errorf("byte %d: got %d, want %d", 16+w, b[0], iterations-1)
+		}
+	}
+}
+
+// TestConcurrentVariableNotFound checks that concurrent lookups of an unknown name all report ErrVariableNotFound.
+func TestConcurrentVariableNotFound(t *testing.T) {
+	c := NewRevPiControlWithBackend(newFakeBackend())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.GetVariableInfo("Missing"); !errors.Is(err, ErrVariableNotFound) {
+				t.Errorf("got %v, want ErrVariableNotFound", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,109 @@
+package gopicontrol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CoreStatusVariable is the variable of the RevPi Core holding its status byte.
+const CoreStatusVariable = "RevPiStatus"
+
+// CoreStatus is the decoded status byte of the RevPi Core (PICONTROL_STATUS_*).
+type CoreStatus struct {
+	Raw           uint8 `json:"raw"`
+	Running       bool  `json:"running"`       // the driver exchanges data with the modules
+	ExtraModule   bool  `json:"extraModule"`   // a module is connected but not configured
+	MissingModule bool  `json:"missingModule"` // a configured module is not connected
+	SizeMismatch  bool  `json:"sizeMismatch"`  // the process image size of a module does not match the configuration
+	LeftGateway   bool  `json:"leftGateway"`   // a gateway is connected on the left
+	RightGateway  bool  `json:"rightGateway"`  // a gateway is connected on the right
+	X2DIN         bool  `json:"x2DIN"`         // the X2 digital input of the RevPi Connect is set
+}
+
+// DecodeCoreStatus decodes the value of the RevPiStatus variable.
+func DecodeCoreStatus(raw uint8) CoreStatus {
+	return CoreStatus{
+		Raw:           raw,
+		Running:       raw&PICONTROL_STATUS_RUNNING != 0,
+		ExtraModule:   raw&PICONTROL_STATUS_EXTRA_MODULE != 0,
+		MissingModule: raw&PICONTROL_STATUS_MISSING_MODULE != 0,
+		SizeMismatch:  raw&PICONTROL_STATUS_SIZE_MISMATCH != 0,
+		LeftGateway:   raw&PICONTROL_STATUS_LEFT_GATEWAY != 0,
+		RightGateway:  raw&PICONTROL_STATUS_RIGHT_GATEWAY != 0,
+		X2DIN:         raw&PICONTROL_STATUS_X2_DIN != 0,
+	}
+}
+
+// Healthy reports whether the driver is running and the connected modules match the configuration.
+func (s CoreStatus) Healthy() bool {
+	return s.Running && !s.ExtraModule && !s.MissingModule && !s.SizeMismatch
+}
+
+func (s CoreStatus) String() string {
+	var flags []string
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{s.Running, "running"},
+		{s.ExtraModule, "extra module"},
+		{s.MissingModule, "missing module"},
+		{s.SizeMismatch, "size mismatch"},
+		{s.LeftGateway, "left gateway"},
+		{s.RightGateway, "right gateway"},
+		{s.X2DIN, "X2 DIN"},
+	} {
+		if f.set {
+			flags = append(flags, f.name)
+		}
+	}
+	if !s.Running {
+		flags = append([]string{"not running"}, flags...)
+	}
+	return fmt.Sprintf("0x%02x (%s)", s.Raw, strings.Join(flags, ", "))
+}
+
+// CoreStatus reads and decodes the status byte of the RevPi Core.
+func (c *RevPiControl) CoreStatus() (CoreStatus, error) {
+	v, err := c.GetVariableInfo(CoreStatusVariable)
+	if err != nil {
+		return CoreStatus{}, err
+	}
+	var b [1]byte
+	if _, err = c.Read(uint32(v.I16uAddress), b[:]); err != nil {
+		return CoreStatus{}, err
+	}
+	return DecodeCoreStatus(b[0]), nil
+}
+
+// WatchCoreStatus polls the status of the RevPi Core every period and delivers it
+// on the returned channel when it changes, starting with the current status.
+// Read errors are retried at the next period. The channel is closed when ctx is done.
+func (c *RevPiControl) WatchCoreStatus(ctx context.Context, period time.Duration) <-chan CoreStatus {
+	ch := make(chan CoreStatus, 1)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		var last *CoreStatus
+		for {
+			if s, err := c.CoreStatus(); err == nil && (last == nil || s != *last) {
+				last = &s
+				select {
+				case ch <- s:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,72 @@
+package gopicontrol_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestDecodeCoreStatus(t *testing.T) {
+	for _, tc := range []struct {
+		raw     uint8
+		want    gopicontrol.CoreStatus
+		healthy bool
+		text    string
+	}{
+		{0x00, gopicontrol.CoreStatus{}, false, "0x00 (not running)"},
+		{0x01, gopicontrol.CoreStatus{Raw: 0x01, Running: true}, true, "0x01 (running)"},
+		{0x05, gopicontrol.CoreStatus{Raw: 0x05, Running: true, MissingModule: true}, false, "0x05 (running, missing module)"},
+		{0x0a, gopicontrol.CoreStatus{Raw: 0x0a, ExtraModule: true, SizeMismatch: true}, false, "0x0a (not running, extra module, size mismatch)"},
+		{0x71, gopicontrol.CoreStatus{Raw: 0x71, Running: true, LeftGateway: true, RightGateway: true, X2DIN: true}, true,
+			"0x71 (running, left gateway, right gateway, X2 DIN)"},
+	} {
+		s := gopicontrol.DecodeCoreStatus(tc.raw)
+		if s != tc.want || s.Healthy() != tc.healthy || s.String() != tc.text {
+			t.Errorf("0x%02x: got %+v healthy %t %q, want %+v healthy %t %q", tc.raw, s, s.Healthy(), s, tc.want, tc.healthy, tc.text)
+		}
+	}
+}
+
+func TestWatchCoreStatus(t *testing.T) {
+	c, b := newSimControl(t)
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	watch, stop := context.WithCancel(ctx)
+	ch := c.WatchCoreStatus(watch, time.Millisecond)
+
+	next := func() gopicontrol.CoreStatus {
+		t.Helper()
+		select {
+		case s, ok := <-ch:
+			if !ok {
+				t.Fatal("the channel was closed")
+			}
+			return s
+		case <-ctx.Done():
+			t.Fatal("no status change")
+		}
+		return gopicontrol.CoreStatus{}
+	}
+
+	if s := next(); !s.Running || !s.Healthy() {
+		t.Errorf("got initial status %v, want running", s)
+	}
+	setValue(t, b, gopicontrol.CoreStatusVariable, gopicontrol.PICONTROL_STATUS_RUNNING|gopicontrol.PICONTROL_STATUS_MISSING_MODULE)
+	if s := next(); !s.MissingModule || s.Healthy() {
+		t.Errorf("got status %v, want a missing module", s)
+	}
+
+	stop()
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("the channel was not closed after the cancellation")
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DIOCounterOffset is the offset of the first 32-bit counter in the input region of a DIO or DI module,
+// after the digital inputs, the input status and the output status.
+const DIOCounterOffset = 6
+
+// counterCount is the number of counters of a DIO or DI module, one per digital input.
+const counterCount = 16
+
+// CounterSample is a reading of a Counter.
+type CounterSample struct {
+	Time  time.Time
+	Raw   uint32  // value in the process image
+	Delta int64   // change since the previous sample, negative for encoders counting down
+	Total int64   // change since the first sample, the first after the last Reset, without wrap-around
+	Rate  float64 // Delta per second since the previous sample, the frequency of the input for counters
+}
+
+// Counter is a counter or encoder input of a DIO or DI module, accessed by variable name.
+// Consecutive samples are compared to handle the wrap-around of the 32-bit value and to compute rates,
+// so samples must be taken often enough for the value to change by less than 2^31 in between.
+// Counter is safe for concurrent use.
+type Counter struct {
+	ctrl     *RevPiControl
+	variable Variable
+	index    uint // counter number on the module, 0 for the counter of input 1
+
+	mu   sync.Mutex
+	last *CounterSample
+}
+
+// NewCounter resolves the counter or encoder variable name, e.g. "Counter_1".
+// The variable must be one of the 32-bit counter inputs of a DIO or DI module.
+func NewCounter(c *RevPiControl, name string) (*Counter, error) {
+	vars, err := c.variables()
+	if err != nil {
+		return nil, err
+	}
+	v, ok := vars.byName[name]
+	if !ok {
+		return nil, variableNotFound(name)
+	}
+
+	if t, ok := LookupModuleType(v.Device.I16uModuleType); !ok || !t.Has(CapCounters) {
+		return nil, fmt.Errorf("variable %s belongs to a %s module without counters", name, GetModuleName(v.Device.I16uModuleType))
+	}
+	rel := int(v.Offset) - int(v.Device.I16uInputOffset) - DIOCounterOffset
+	if v.Type != EntryInput || v.BitLength != 32 || rel < 0 || rel%4 != 0 || rel/4 >= counterCount {
+		return nil, fmt.Errorf("variable %s is not a counter input", name)
+	}
+	return &Counter{ctrl: c, variable: *v, index: uint(rel / 4)}, nil
+}
+
+// Name returns the variable name of the counter.
+func (k *Counter) Name() string {
+	return k.variable.Name
+}
+
+// Input returns the number of the digital input of the counter, from 1 to 16.
+func (k *Counter) Input() int {
+	return int(k.index) + 1
+}
+
+// Raw reads the 32-bit value of the counter.
+func (k *Counter) Raw() (uint32, error) {
+	var b [4]byte
+	n, err := k.ctrl.Read(uint32(k.variable.Offset), b[:])
+	if err != nil {
+		return 0, err
+	}
+	if n != len(b) {
+		return 0, fmt.Errorf("could not read counter %s: %d of %d bytes read", k.variable.Name, n, len(b))
+	}
+	return leUint32(b[:]), nil
+}
+
+// Sample reads the counter and computes the change and the rate since the previous sample.
+// The first sample has no delta nor rate.
+func (k *Counter) Sample() (CounterSample, error) {
+	raw, err := k.Raw()
+	if err != nil {
+		return CounterSample{}, err
+	}
+	s := CounterSample{Time: time.Now(), Raw: raw}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.last != nil {
+		// the difference of the 32-bit values is correct across a wrap-around in both directions
+		s.Delta = int64(int32(raw - k.last.Raw))
+		s.Total = k.last.Total + s.Delta
+		if dt := s.Time.Sub(k.last.Time).Seconds(); dt > 0 {
+			s.Rate = float64(s.Delta) / dt
+		}
+	}
+	k.last = &s
+	return s, nil
+}
+
+// Reset sets the counter to 0 with KB_DIO_RESET_COUNTER and restarts the totals:
+// the next sample is taken as a first sample, without delta nor rate.
+func (k *Counter) Reset() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, err := k.ctrl.ResetCounter(k.variable.Device.I8uAddress, 1<<k.index); err != nil {
+		return err
+	}
+	k.last = nil
+	return nil
+}
+
+// ResetCounters resets the counters named in names, grouping them in one call per module.
+func (c *RevPiControl) ResetCounters(names ...string) error {
+	bitfields := map[uint8]uint16{}
+	var order []uint8
+	for _, name := range names {
+		k, err := NewCounter(c, name)
+		if err != nil {
+			return err
+		}
+		address := k.variable.Device.I8uAddress
+		if _, ok := bitfields[address]; !ok {
+			order = append(order, address)
+		}
+		bitfields[address] |= 1 << k.index
+	}
+	for _, address := range order {
+		if _, err := c.ResetCounter(address, bitfields[address]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
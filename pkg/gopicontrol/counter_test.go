@@ -0,0 +1,99 @@
+package gopicontrol_test
+
+import (
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestCounterWrapAround(t *testing.T) {
+	c, b := newSimControl(t)
+	k, err := gopicontrol.NewCounter(c, "Counter_2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Input() != 2 {
+		t.Errorf("got input %d, want 2", k.Input())
+	}
+
+	for _, tc := range []struct {
+		raw          uint32
+		delta, total int64
+	}{
+		{0xfffffff0, 0, 0},
+		{0x10, 0x20, 0x20},     // counting up through the wrap-around
+		{0xfffffff8, -0x18, 8}, // counting down through the wrap-around
+	} {
+		setValue(t, b, "Counter_2", tc.raw)
+		s, err := k.Sample()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Raw != tc.raw || s.Delta != tc.delta || s.Total != tc.total {
+			t.Errorf("raw 0x%x: got delta %d total %d, want %d and %d", tc.raw, s.Delta, s.Total, tc.delta, tc.total)
+		}
+	}
+}
+
+func TestCounterReset(t *testing.T) {
+	c, b := newSimControl(t)
+	k, err := gopicontrol.NewCounter(c, "Counter_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setValue(t, b, "Counter_1", 100)
+	if _, err = k.Sample(); err != nil {
+		t.Fatal(err)
+	}
+	if err = k.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if got := value(t, b, "Counter_1"); got != 0 {
+		t.Fatalf("got counter %d after Reset, want 0", got)
+	}
+
+	// the module applies the reset in a later cycle and counts on
+	setValue(t, b, "Counter_1", 7)
+	s, err := k.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Delta != 0 || s.Total != 0 || s.Rate != 0 {
+		t.Errorf("got delta %d total %d rate %v after Reset, want a first sample", s.Delta, s.Total, s.Rate)
+	}
+	setValue(t, b, "Counter_1", 12)
+	if s, err = k.Sample(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Delta != 5 || s.Total != 5 {
+		t.Errorf("got delta %d total %d, want 5 and 5", s.Delta, s.Total)
+	}
+}
+
+func TestResetCountersGroupsModules(t *testing.T) {
+	c, b := newSimControl(t)
+	for _, name := range []string{"Counter_1", "Counter_2", "DI_Counter_1"} {
+		setValue(t, b, name, 100)
+	}
+
+	if err := c.ResetCounters("Counter_2", "DI_Counter_1", "Counter_1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []counterReset{{32, 0x3}, {33, 0x1}}
+	if len(b.counterResets) != len(want) || b.counterResets[0] != want[0] || b.counterResets[1] != want[1] {
+		t.Errorf("got resets %+v, want %+v", b.counterResets, want)
+	}
+	for _, name := range []string{"Counter_1", "Counter_2", "DI_Counter_1"} {
+		if got := value(t, b, name); got != 0 {
+			t.Errorf("got %s %d, want 0", name, got)
+		}
+	}
+
+	b.counterResets = nil
+	if err := c.ResetCounters("Counter_1", "InputStatus"); err == nil {
+		t.Error("resetting InputStatus succeeded")
+	}
+	if len(b.counterResets) != 0 {
+		t.Errorf("got resets %+v with an invalid name, want none", b.counterResets)
+	}
+}
@@ -16,6 +16,8 @@ type SPIVariable C.struct_SPIVariableStr
 
 type SDIOResetCounter C.struct_SDIOResetCounterStr
 
+type SConfigData C.struct_SConfigDataStr
+
 const (
 	PICONTROL_DEVICE               = C.PICONTROL_DEVICE
 	KB_RESET                       = C.KB_RESET
@@ -24,16 +26,54 @@ const (
 	KB_GET_VALUE                   = C.KB_GET_VALUE
 	KB_SET_VALUE                   = C.KB_SET_VALUE
 	KB_FIND_VARIABLE               = C.KB_FIND_VARIABLE
+	KB_SET_EXPORTED_OUTPUTS        = C.KB_SET_EXPORTED_OUTPUTS
 	KB_DIO_RESET_COUNTER           = C.KB_DIO_RESET_COUNTER
 	KB_UPDATE_DEVICE_FIRMWARE      = C.KB_UPDATE_DEVICE_FIRMWARE
 	KB_GET_LAST_MESSAGE            = C.KB_GET_LAST_MESSAGE
+	KB_STOP_IO                     = C.KB_STOP_IO
+	KB_CONFIG_STOP                 = C.KB_CONFIG_STOP
+	KB_CONFIG_SEND                 = C.KB_CONFIG_SEND
+	KB_CONFIG_START                = C.KB_CONFIG_START
+	KB_SET_OUTPUT_WATCHDOG         = C.KB_SET_OUTPUT_WATCHDOG
 	KB_INTERN_IO_MSG               = C.KB_INTERN_IO_MSG
 	KB_WAIT_FOR_EVENT              = C.KB_WAIT_FOR_EVENT
+	KB_EVENT_RESET                 = C.KB_EVENT_RESET
+	CONFIG_DATA_SIZE               = C.CONFIG_DATA_SIZE
+	REV_PI_ERROR_MSG_LEN           = C.REV_PI_ERROR_MSG_LEN
+	REV_PI_DEV_FIRST_RIGHT         = C.REV_PI_DEV_FIRST_RIGHT
+	REV_PI_DEV_CNT_MAX             = C.REV_PI_DEV_CNT_MAX
+	PICONTROL_STATUS_RUNNING         = C.PICONTROL_STATUS_RUNNING
+	PICONTROL_STATUS_EXTRA_MODULE    = C.PICONTROL_STATUS_EXTRA_MODULE
+	PICONTROL_STATUS_MISSING_MODULE  = C.PICONTROL_STATUS_MISSING_MODULE
+	PICONTROL_STATUS_SIZE_MISMATCH   = C.PICONTROL_STATUS_SIZE_MISMATCH
+	PICONTROL_STATUS_LEFT_GATEWAY    = C.PICONTROL_STATUS_LEFT_GATEWAY
+	PICONTROL_STATUS_RIGHT_GATEWAY   = C.PICONTROL_STATUS_RIGHT_GATEWAY
+	PICONTROL_STATUS_X2_DIN          = C.PICONTROL_STATUS_X2_DIN
+	PICONTROL_LED_A1_GREEN           = C.PICONTROL_LED_A1_GREEN
+	PICONTROL_LED_A1_RED             = C.PICONTROL_LED_A1_RED
+	PICONTROL_LED_A2_GREEN           = C.PICONTROL_LED_A2_GREEN
+	PICONTROL_LED_A2_RED             = C.PICONTROL_LED_A2_RED
+	PICONTROL_LED_A3_GREEN           = C.PICONTROL_LED_A3_GREEN
+	PICONTROL_LED_A3_RED             = C.PICONTROL_LED_A3_RED
+	PICONTROL_X2_DOUT                = C.PICONTROL_X2_DOUT
+	PICONTROL_WD_TRIGGER             = C.PICONTROL_WD_TRIGGER
+	PICONTROL_CONFIG_ERROR_WRONG_MODULE_TYPE   = C.PICONTROL_CONFIG_ERROR_WRONG_MODULE_TYPE
+	PICONTROL_CONFIG_ERROR_WRONG_INPUT_LENGTH  = C.PICONTROL_CONFIG_ERROR_WRONG_INPUT_LENGTH
+	PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_LENGTH = C.PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_LENGTH
+	PICONTROL_CONFIG_ERROR_WRONG_CONFIG_LENGTH = C.PICONTROL_CONFIG_ERROR_WRONG_CONFIG_LENGTH
+	PICONTROL_CONFIG_ERROR_WRONG_INPUT_OFFSET  = C.PICONTROL_CONFIG_ERROR_WRONG_INPUT_OFFSET
+	PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_OFFSET = C.PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_OFFSET
+	PICONTROL_CONFIG_ERROR_WRONG_CONFIG_OFFSET = C.PICONTROL_CONFIG_ERROR_WRONG_CONFIG_OFFSET
 	PICONTROL_NOT_CONNECTED        = C.PICONTROL_NOT_CONNECTED
 	PICONTROL_NOT_CONNECTED_MASK   = C.PICONTROL_NOT_CONNECTED_MASK
+	PICONTROL_SW_OFFSET            = C.PICONTROL_SW_OFFSET
 	PICONTROL_SW_MODBUS_TCP_SLAVE  = C.PICONTROL_SW_MODBUS_TCP_SLAVE
 	PICONTROL_SW_MODBUS_RTU_SLAVE  = C.PICONTROL_SW_MODBUS_RTU_SLAVE
 	PICONTROL_SW_MODBUS_TCP_MASTER = C.PICONTROL_SW_MODBUS_TCP_MASTER
 	PICONTROL_SW_MODBUS_RTU_MASTER = C.PICONTROL_SW_MODBUS_RTU_MASTER
+	PICONTROL_SW_PROFINET_CONTROLLER = C.PICONTROL_SW_PROFINET_CONTROLLER
+	PICONTROL_SW_PROFINET_DEVICE     = C.PICONTROL_SW_PROFINET_DEVICE
+	PICONTROL_SW_REVPI_SEVEN         = C.PICONTROL_SW_REVPI_SEVEN
+	PICONTROL_SW_REVPI_CLOUD         = C.PICONTROL_SW_REVPI_CLOUD
 	//ENODEV            = C.ENODEV
 )
@@ -0,0 +1,182 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DeviceSide is the position of a device relative to the RevPi Core.
+type DeviceSide int
+
+// Device sides, devices on the right have addresses from REV_PI_DEV_FIRST_RIGHT.
+const (
+	SideCore DeviceSide = iota
+	SideLeft
+	SideRight
+)
+
+func (s DeviceSide) String() string {
+	switch s {
+	case SideLeft:
+		return "left"
+	case SideRight:
+		return "right"
+	default:
+		return "core"
+	}
+}
+
+// MarshalText encodes the side as its name in JSON.
+func (s DeviceSide) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// DeviceState is the state of a device as seen by the driver.
+type DeviceState int
+
+// Device states.
+const (
+	// DeviceActive is configured and present, its data is exchanged.
+	DeviceActive DeviceState = iota
+	// DeviceNotPresent is configured but not connected, its data is not available.
+	DeviceNotPresent
+	// DeviceNotConfigured is connected but not part of the configuration.
+	DeviceNotConfigured
+)
+
+func (s DeviceState) String() string {
+	switch s {
+	case DeviceActive:
+		return "active"
+	case DeviceNotPresent:
+		return "not present"
+	default:
+		return "not configured"
+	}
+}
+
+// MarshalText encodes the state as its name in JSON.
+func (s DeviceState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Device is a device of the process image decoded from SDeviceInfo.
+type Device struct {
+	Address      uint8        `json:"address"`
+	Side         DeviceSide   `json:"side"`
+	ModuleType   uint16       `json:"moduleType"` // without the PICONTROL_NOT_CONNECTED flag
+	Name         string       `json:"name"`
+	Family       ModuleFamily `json:"family"`
+	SerialNumber uint32       `json:"serialNumber"`
+	HWRevision   uint16       `json:"hwRevision"`
+	SWMajor      uint16       `json:"swMajor"`
+	SWMinor      uint16       `json:"swMinor"`
+	SVNRevision  uint32       `json:"svnRevision"`
+	State        DeviceState  `json:"state"`
+	ModuleState  uint8        `json:"moduleState"` // raw I8uModuleState
+	Input        Region       `json:"input"`
+	Output       Region       `json:"output"`
+	Config       Region       `json:"config"`
+	FirstEntry   uint16       `json:"firstEntry"`
+	Entries      uint16       `json:"entries"`
+
+	Info SDeviceInfo `json:"-"` // raw description
+}
+
+// NewDevice decodes a device description of the driver.
+func NewDevice(info SDeviceInfo) Device {
+	d := Device{
+		Address:      info.I8uAddress,
+		ModuleType:   info.I16uModuleType & PICONTROL_NOT_CONNECTED_MASK,
+		Name:         GetModuleName(info.I16uModuleType),
+		Family:       FamilyOf(info.I16uModuleType),
+		SerialNumber: info.I32uSerialnumber,
+		HWRevision:   info.I16uHW_Revision,
+		SWMajor:      info.I16uSW_Major,
+		SWMinor:      info.I16uSW_Minor,
+		SVNRevision:  info.I32uSVN_Revision,
+		ModuleState:  info.I8uModuleState,
+		Input:        InputRegion(info),
+		Output:       OutputRegion(info),
+		Config:       Region{Offset: uint32(info.I16uConfigOffset), Length: uint32(info.I16uConfigLength)},
+		FirstEntry:   info.I16uFirstEntry,
+		Entries:      info.I16uEntries,
+		Info:         info,
+	}
+
+	switch {
+	case info.I8uAddress == 0:
+		d.Side = SideCore
+	case info.I8uAddress < REV_PI_DEV_FIRST_RIGHT:
+		d.Side = SideLeft
+	default:
+		d.Side = SideRight
+	}
+
+	switch {
+	case info.I8uActive != 0:
+		d.State = DeviceActive
+	case info.I16uModuleType&PICONTROL_NOT_CONNECTED != 0:
+		d.State = DeviceNotPresent
+	default:
+		d.State = DeviceNotConfigured
+	}
+	return d
+}
+
+// FirmwareVersion returns the firmware version as major.minor.
+func (d Device) FirmwareVersion() string {
+	return fmt.Sprintf("%d.%d", d.SWMajor, d.SWMinor)
+}
+
+func (d Device) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (type %d) at address %d", d.Name, d.ModuleType, d.Address)
+	if d.Side != SideCore {
+		fmt.Fprintf(&b, " %s of the core", d.Side)
+	}
+	fmt.Fprintf(&b, ", V%s HW %d serial %d, %s", d.FirmwareVersion(), d.HWRevision, d.SerialNumber, d.State)
+	return b.String()
+}
+
+// Devices returns the decoded descriptions of all configured and detected devices.
+func (c *RevPiControl) Devices() ([]Device, error) {
+	infos, err := c.GetDeviceInfoList()
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]Device, len(infos))
+	for i, info := range infos {
+		devices[i] = NewDevice(info)
+	}
+	return devices, nil
+}
+
+// DeviceByAddress returns the device at address, the error wraps ErrDeviceNotFound if there is none.
+func (c *RevPiControl) DeviceByAddress(address uint8) (Device, error) {
+	devices, err := c.Devices()
+	if err != nil {
+		return Device{}, err
+	}
+	for _, d := range devices {
+		if d.Address == address {
+			return d, nil
+		}
+	}
+	return Device{}, deviceNotFound(address)
+}
+
+// DevicesByType returns the devices of a module type, ignoring its PICONTROL_NOT_CONNECTED flag.
+func (c *RevPiControl) DevicesByType(moduletype uint16) ([]Device, error) {
+	devices, err := c.Devices()
+	if err != nil {
+		return nil, err
+	}
+	var found []Device
+	for _, d := range devices {
+		if d.ModuleType == moduletype&PICONTROL_NOT_CONNECTED_MASK {
+			found = append(found, d)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,101 @@
+package gopicontrol_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestDeviceByAddress(t *testing.T) {
+	c, _ := newSimControl(t)
+	d, err := c.DeviceByAddress(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "RevPi DIO" || d.Side != gopicontrol.SideRight || d.Family != gopicontrol.FamilyIO || d.State != gopicontrol.DeviceActive {
+		t.Errorf("got %s, want an active RevPi DIO on the right", d)
+	}
+	if d.Input != (gopicontrol.Region{Offset: 2, Length: 14}) || d.Output != (gopicontrol.Region{Offset: 16, Length: 6}) {
+		t.Errorf("got inputs %+v and outputs %+v, want 2+14 and 16+6", d.Input, d.Output)
+	}
+	if _, err = c.DeviceByAddress(40); !errors.Is(err, gopicontrol.ErrDeviceNotFound) {
+		t.Errorf("got %v, want ErrDeviceNotFound", err)
+	}
+}
+
+func TestDevicesByType(t *testing.T) {
+	c, _ := newSimControl(t)
+	for _, tc := range []struct {
+		moduletype uint16
+		addresses  string
+	}{
+		{gopicontrol.ModuleTypeDIO, "[32]"},
+		{gopicontrol.ModuleTypeDI | gopicontrol.PICONTROL_NOT_CONNECTED, "[33]"},
+		{95, "[0]"},
+		{gopicontrol.ModuleTypeDO, "[]"},
+	} {
+		devices, err := c.DevicesByType(tc.moduletype)
+		if err != nil {
+			t.Fatal(err)
+		}
+		addresses := []uint8{}
+		for _, d := range devices {
+			addresses = append(addresses, d.Address)
+		}
+		if got := fmt.Sprint(addresses); got != tc.addresses {
+			t.Errorf("got devices of type 0x%x at %s, want %s", tc.moduletype, got, tc.addresses)
+		}
+	}
+}
+
+func TestNewDevice(t *testing.T) {
+	d := gopicontrol.NewDevice(gopicontrol.SDeviceInfo{
+		I8uAddress:     5,
+		I16uModuleType: 71 | gopicontrol.PICONTROL_NOT_CONNECTED,
+		I16uSW_Major:   1,
+		I16uSW_Minor:   2,
+	})
+	if d.ModuleType != 71 || d.Side != gopicontrol.SideLeft || d.Family != gopicontrol.FamilyGateway || d.State != gopicontrol.DeviceNotPresent {
+		t.Errorf("got %s, want a gateway not present on the left", d)
+	}
+	if d.FirmwareVersion() != "1.2" {
+		t.Errorf("got firmware %s, want 1.2", d.FirmwareVersion())
+	}
+	if d = gopicontrol.NewDevice(gopicontrol.SDeviceInfo{I8uAddress: 40, I16uModuleType: 96}); d.State != gopicontrol.DeviceNotConfigured {
+		t.Errorf("got state %v of an inactive connected device, want not configured", d.State)
+	}
+}
+
+func TestDeviceJSON(t *testing.T) {
+	c, _ := newSimControl(t)
+	d, err := c.DeviceByAddress(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{
+		"address":    32.0,
+		"side":       "right",
+		"moduleType": 96.0,
+		"family":     "IO",
+		"state":      "active",
+		"input":      map[string]interface{}{"offset": 2.0, "length": 14.0},
+	} {
+		if got := m[key]; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got %s %v, want %v", key, got, want)
+		}
+	}
+	if _, ok := m["Info"]; ok {
+		t.Error("the raw description is encoded")
+	}
+}
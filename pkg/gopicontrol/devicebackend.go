@@ -0,0 +1,434 @@
+package gopicontrol
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/mezzato/revpi/pkg/piconfig"
+	"golang.org/x/sys/unix"
+)
+
+// see /home/enrico/go_src/src/golang.org/x/sys/unix/zsyscall_linux_arm64.go
+// https://github.com/golang/crypto/blob/master/ssh/terminal/util.go
+
+// Do the interface allocations only once for common
+// Errno values.
+var (
+	errEAGAIN error = syscall.EAGAIN
+	errEINVAL error = syscall.EINVAL
+	errENOENT error = syscall.ENOENT
+)
+
+// errnoErr returns common boxed Errno values, to prevent
+// allocations at runtime.
+func errnoErr(e syscall.Errno) error {
+	switch e {
+	case 0:
+		return nil
+	case unix.EAGAIN:
+		return errEAGAIN
+	case unix.EINVAL:
+		return errEINVAL
+	case unix.ENOENT:
+		return errENOENT
+	}
+	return e
+}
+
+// ioctl invokes a Unix syscall.
+func ioctl(fd uintptr, req uint, arg uintptr) (r1 uintptr, r2 uintptr, err error) {
+	r1, r2, e1 := unix.Syscall(unix.SYS_IOCTL, fd, uintptr(req), uintptr(arg))
+	if e1 != 0 {
+		err = errnoErr(e1)
+		return r1, r2, err
+	}
+	return r1, r2, nil
+}
+
+// DeviceBackend is the Backend implementation using the piControl kernel driver through its device file.
+// It is safe for concurrent use: the process image is accessed with positional reads and writes (pread/pwrite)
+// and the file handle is guarded, so that Close and Reset wait for the pending calls.
+type DeviceBackend struct {
+	// ConfigFile is the piCtory configuration loaded by the driver, it is used to build
+	// the table of entries which the driver does not export.
+	// If empty the default locations of piconfig.Load are used.
+	ConfigFile string
+
+	path   string
+	mu     sync.RWMutex
+	handle *os.File
+}
+
+// NewDeviceBackend creates a backend for the piControl device file at path, e.g. PICONTROL_DEVICE.
+func NewDeviceBackend(path string) *DeviceBackend {
+	return &DeviceBackend{path: path}
+}
+
+// Path returns the path of the piControl device file.
+func (b *DeviceBackend) Path() string {
+	return b.path
+}
+
+// Open opens the file handle.
+// see also: golang.org/x/sys/unix/syscall_unix_test.go
+func (b *DeviceBackend) Open() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open()
+}
+
+// open opens the file handle if needed, b.mu must be locked.
+func (b *DeviceBackend) open() (err error) {
+	if b.handle != nil {
+		return nil
+	}
+
+	b.handle, err = os.OpenFile(b.path, os.O_RDWR, 0)
+	if err != nil {
+		b.handle = nil
+		return fmt.Errorf("could not open %s: %w", b.path, newDriverError("open", err))
+	}
+	return nil
+}
+
+// Close closes the file handle.
+func (b *DeviceBackend) Close() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handle != nil {
+		if err = b.handle.Close(); err != nil {
+			return err
+		}
+		b.handle = nil
+	}
+	return nil
+}
+
+// file returns the open file handle, opening it if needed.
+// The handle is read locked on return, the caller must call b.mu.RUnlock when done.
+func (b *DeviceBackend) file() (*os.File, error) {
+	b.mu.RLock()
+	if b.handle != nil {
+		return b.handle, nil
+	}
+	b.mu.RUnlock()
+
+	b.mu.Lock()
+	if err := b.open(); err != nil {
+		b.mu.Unlock()
+		return nil, err
+	}
+	b.mu.Unlock()
+	return b.file()
+}
+
+// ioctl invokes a piControl ioctl on the file handle.
+func (b *DeviceBackend) ioctl(req uint, arg uintptr) (r uintptr, err error) {
+	f, err := b.file()
+	if err != nil {
+		return 0, err
+	}
+	defer b.mu.RUnlock()
+
+	if r, _, err = ioctl(f.Fd(), req, arg); err != nil {
+		return r, newDriverError(ioctlName(req), err)
+	}
+	return r, nil
+}
+
+// call invokes a piControl ioctl like ioctl and also turns a negative result into a DriverError.
+func (b *DeviceBackend) call(req uint, arg uintptr) (r uintptr, err error) {
+	if r, err = b.ioctl(req, arg); err != nil {
+		return r, err
+	}
+	if code := int(int32(r)); code < 0 {
+		return r, &DriverError{Op: ioctlName(req), Code: code}
+	}
+	return r, nil
+}
+
+// ioctlName returns the name of a piControl ioctl request for error messages.
+func ioctlName(req uint) string {
+	switch req {
+	case KB_RESET:
+		return "KB_RESET"
+	case KB_GET_DEVICE_INFO:
+		return "KB_GET_DEVICE_INFO"
+	case KB_GET_DEVICE_INFO_LIST:
+		return "KB_GET_DEVICE_INFO_LIST"
+	case KB_GET_VALUE:
+		return "KB_GET_VALUE"
+	case KB_SET_VALUE:
+		return "KB_SET_VALUE"
+	case KB_FIND_VARIABLE:
+		return "KB_FIND_VARIABLE"
+	case KB_SET_EXPORTED_OUTPUTS:
+		return "KB_SET_EXPORTED_OUTPUTS"
+	case KB_UPDATE_DEVICE_FIRMWARE:
+		return "KB_UPDATE_DEVICE_FIRMWARE"
+	case KB_DIO_RESET_COUNTER:
+		return "KB_DIO_RESET_COUNTER"
+	case KB_GET_LAST_MESSAGE:
+		return "KB_GET_LAST_MESSAGE"
+	case KB_STOP_IO:
+		return "KB_STOP_IO"
+	case KB_CONFIG_STOP:
+		return "KB_CONFIG_STOP"
+	case KB_CONFIG_SEND:
+		return "KB_CONFIG_SEND"
+	case KB_CONFIG_START:
+		return "KB_CONFIG_START"
+	case KB_SET_OUTPUT_WATCHDOG:
+		return "KB_SET_OUTPUT_WATCHDOG"
+	case KB_WAIT_FOR_EVENT:
+		return "KB_WAIT_FOR_EVENT"
+	default:
+		return fmt.Sprintf("ioctl 0x%x", req)
+	}
+}
+
+// Reset initializes the Pi Control Interface.
+// The handle is locked exclusively so that no other call is in progress during the reset.
+func (b *DeviceBackend) Reset() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err = b.open(); err != nil {
+		return err
+	}
+
+	if _, _, err = ioctl(b.handle.Fd(), KB_RESET, uintptr(0)); err != nil {
+		return newDriverError("KB_RESET", err)
+	}
+	return nil
+}
+
+// Read gets process data from a specific position, reads len(pData) bytes from file.
+// Returns number of bytes read or error.
+func (b *DeviceBackend) Read(offset uint32, pData []byte) (n int, err error) {
+	f, err := b.file()
+	if err != nil {
+		return -1, err
+	}
+	defer b.mu.RUnlock()
+
+	// positional read, the file offset is not shared between goroutines
+	n, err = f.ReadAt(pData, int64(offset))
+	if err == io.EOF && n > 0 {
+		// end of the process image
+		err = nil
+	}
+	if err != nil {
+		return n, newDriverError("read", err)
+	}
+	return n, nil
+}
+
+// Write writes process data at a specific position, writes len(pData) bytes to file.
+// Returns number of bytes written or error.
+func (b *DeviceBackend) Write(offset uint32, pData []byte) (n int, err error) {
+	f, err := b.file()
+	if err != nil {
+		return -1, err
+	}
+	defer b.mu.RUnlock()
+
+	// positional write, the file offset is not shared between goroutines
+	if n, err = f.WriteAt(pData, int64(offset)); err != nil {
+		return n, newDriverError("write", err)
+	}
+	return n, nil
+}
+
+// GetDeviceInfo gets a description of a connected device.
+func (b *DeviceBackend) GetDeviceInfo(devInfo *SDeviceInfo) (result int, err error) {
+	var r uintptr
+	if r, err = b.ioctl(KB_GET_DEVICE_INFO, uintptr(unsafe.Pointer(devInfo))); err != nil {
+		return 0, err
+	}
+
+	return int(r), nil
+}
+
+// GetDeviceInfoList gets a description of connected devices.
+func (b *DeviceBackend) GetDeviceInfoList() (devInfo []SDeviceInfo, err error) {
+	asDevList := make([]SDeviceInfo, REV_PI_DEV_CNT_MAX)
+	var r uintptr
+	if r, err = b.ioctl(KB_GET_DEVICE_INFO_LIST, uintptr(unsafe.Pointer(&asDevList[0]))); err != nil {
+		return nil, err
+	}
+
+	// cut off the slice
+	devInfo = asDevList[:int(r)]
+
+	return devInfo, nil
+}
+
+// GetEntryInfoList gets the table of entries from the piCtory configuration.
+func (b *DeviceBackend) GetEntryInfoList() ([]SEntryInfo, error) {
+	var (
+		cfg *piconfig.Config
+		err error
+	)
+	if b.ConfigFile != "" {
+		cfg, err = piconfig.LoadFile(b.ConfigFile)
+	} else {
+		cfg, err = piconfig.Load()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return EntryInfoList(cfg)
+}
+
+// GetBitValue gets the value of one bit in the process image.
+func (b *DeviceBackend) GetBitValue(pSpiValue *SPIValue) (err error) {
+	if _, err = b.ioctl(KB_GET_VALUE, uintptr(unsafe.Pointer(pSpiValue))); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetBitValue sets the value of one bit in the process image.
+func (b *DeviceBackend) SetBitValue(pSpiValue *SPIValue) (err error) {
+	if _, err = b.ioctl(KB_SET_VALUE, uintptr(unsafe.Pointer(pSpiValue))); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetVariableInfo gets information about a variable by name.
+func (b *DeviceBackend) GetVariableInfo(name string) (pSpiVariable *SPIVariable, err error) {
+	var v SPIVariable
+	v.StrVarName = ByteToUint8Array(([]byte)(name))
+	if _, err = b.call(KB_FIND_VARIABLE, uintptr(unsafe.Pointer(&v))); err != nil {
+		var derr *DriverError
+		if errors.As(err, &derr) && (derr.Errno == unix.ENOENT || derr.Code < 0) {
+			return nil, variableNotFound(name)
+		}
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// SetExportedOutputs copies the exported outputs from image to the process image in one driver call.
+// image must be a whole process image of ProcessImageLength bytes, the other bytes are ignored.
+func (b *DeviceBackend) SetExportedOutputs(image []byte) (err error) {
+	if len(image) != ProcessImageLength {
+		return fmt.Errorf("exported outputs image has %d bytes instead of %d", len(image), ProcessImageLength)
+	}
+	_, err = b.call(KB_SET_EXPORTED_OUTPUTS, uintptr(unsafe.Pointer(&image[0])))
+	return err
+}
+
+// ResetCounter resets a counter.
+func (b *DeviceBackend) ResetCounter(address uint8, bitfield uint16) (result int, err error) {
+	var tel SDIOResetCounter
+	var r uintptr
+
+	tel.I8uAddress = address
+	tel.I16uBitfield = bitfield
+
+	if r, err = b.call(KB_DIO_RESET_COUNTER, uintptr(unsafe.Pointer(&tel))); err != nil {
+		return int(int32(r)), err
+	}
+
+	return int(r), nil
+}
+
+// SetOutputWatchdog activates the output watchdog of the handle, if no write happens within timeoutMs
+// the driver sets all outputs to 0. A timeout of 0 deactivates the watchdog.
+func (b *DeviceBackend) SetOutputWatchdog(timeoutMs uint32) (err error) {
+	// the driver reads an unsigned long
+	timeout := uint(timeoutMs)
+	if _, err = b.ioctl(KB_SET_OUTPUT_WATCHDOG, uintptr(unsafe.Pointer(&timeout))); err != nil {
+		return err
+	}
+	return nil
+}
+
+// StopIO stops, starts or toggles the I/O communication with the modules and returns the new state.
+func (b *DeviceBackend) StopIO(mode int) (stopped bool, err error) {
+	stop := int32(mode)
+	r, err := b.call(KB_STOP_IO, uintptr(unsafe.Pointer(&stop)))
+	if err != nil {
+		return false, err
+	}
+	return r != 0, nil
+}
+
+// ConfigStop stops the I/O communication to download a configuration to a master gateway.
+func (b *DeviceBackend) ConfigStop() error {
+	_, err := b.call(KB_CONFIG_STOP, 0)
+	return err
+}
+
+// ConfigSend sends a chunk of the configuration to the left or right master gateway.
+func (b *DeviceBackend) ConfigSend(data *SConfigData) error {
+	_, err := b.call(KB_CONFIG_SEND, uintptr(unsafe.Pointer(data)))
+	return err
+}
+
+// ConfigStart restarts the I/O communication after a configuration download.
+func (b *DeviceBackend) ConfigStart() error {
+	_, err := b.call(KB_CONFIG_START, 0)
+	return err
+}
+
+// WaitForEvent waits for an event of the Pi Control Interface, e.g. a reset.
+// The call blocks on a duplicate of the file handle without holding the handle lock,
+// so that Close and Reset are not delayed: a Close does not end a pending wait,
+// which returns with the next event of the driver.
+func (b *DeviceBackend) WaitForEvent() (event int, err error) {
+	f, err := b.file()
+	if err != nil {
+		return 0, err
+	}
+	fd, err := unix.Dup(int(f.Fd()))
+	b.mu.RUnlock()
+	if err != nil {
+		return 0, newDriverError("KB_WAIT_FOR_EVENT", err)
+	}
+	defer unix.Close(fd)
+
+	var ev int32
+	if _, _, err = ioctl(uintptr(fd), KB_WAIT_FOR_EVENT, uintptr(unsafe.Pointer(&ev))); err != nil {
+		return 0, newDriverError("KB_WAIT_FOR_EVENT", err)
+	}
+	return int(ev), nil
+}
+
+// LastMessage returns the last message of the driver, e.g. the result of a firmware update.
+func (b *DeviceBackend) LastMessage() (msg string, err error) {
+	var buf [REV_PI_ERROR_MSG_LEN]byte
+	if _, err = b.ioctl(KB_GET_LAST_MESSAGE, uintptr(unsafe.Pointer(&buf[0]))); err != nil {
+		return "", err
+	}
+	return cString(buf[:]), nil
+}
+
+// UpdateFirmware update a device firmware, check on the Kunbus website for details about updating firmware.
+// On failure the message of the driver is returned in the DriverError.
+func (b *DeviceBackend) UpdateFirmware(addrP uint32) (result int, err error) {
+	var r uintptr
+	if addrP == 0 {
+		r, err = b.call(KB_UPDATE_DEVICE_FIRMWARE, 0)
+	} else {
+		r, err = b.call(KB_UPDATE_DEVICE_FIRMWARE, uintptr(unsafe.Pointer(&addrP)))
+	}
+
+	var derr *DriverError
+	if errors.As(err, &derr) {
+		derr.Message, _ = b.LastMessage()
+		return int(int32(r)), derr
+	}
+	if err != nil {
+		return -1, err
+	}
+	return int(r), nil
+}
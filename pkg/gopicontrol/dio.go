@@ -0,0 +1,259 @@
+package gopicontrol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Process image layout of DIO, DI and DO modules, relative to the input and output regions.
+const (
+	dioOutputStatusOffset = 4 // input region: 16 bit output status, bit n set if output n+1 has a fault
+	dioPWMOffset          = 2 // output region: one byte duty cycle in percent per output
+	dioChannels           = 16
+)
+
+// OutputFault is a fault reported by a DIO or DO module on an output: overload, short circuit or overtemperature.
+type OutputFault struct {
+	Address uint8 // address of the module
+	Output  int   // output number, from 1 to 16
+}
+
+func (f *OutputFault) Error() string {
+	return fmt.Sprintf("%v on output %d of the module at address %d", ErrOutputFault, f.Output, f.Address)
+}
+
+// Unwrap returns ErrOutputFault.
+func (f *OutputFault) Unwrap() error {
+	return ErrOutputFault
+}
+
+// OutputFaults is the list of faulty outputs of a module, it wraps ErrOutputFault.
+type OutputFaults []*OutputFault
+
+func (f OutputFaults) Error() string {
+	switch len(f) {
+	case 0:
+		return "no output fault"
+	case 1:
+		return f[0].Error()
+	}
+	outputs := make([]string, len(f))
+	for i, o := range f {
+		outputs[i] = fmt.Sprint(o.Output)
+	}
+	return fmt.Sprintf("%v on outputs %s of the module at address %d", ErrOutputFault, strings.Join(outputs, ", "), f[0].Address)
+}
+
+// Is reports whether target is ErrOutputFault.
+func (f OutputFaults) Is(target error) bool {
+	return target == ErrOutputFault && len(f) > 0
+}
+
+// DecodeOutputFaults returns the faults of the output status word of the module at address, nil if there is none.
+func DecodeOutputFaults(address uint8, status uint16) OutputFaults {
+	var faults OutputFaults
+	for n := 0; n < dioChannels; n++ {
+		if status&(1<<n) != 0 {
+			faults = append(faults, &OutputFault{Address: address, Output: n + 1})
+		}
+	}
+	return faults
+}
+
+// DigitalOutputs gives typed access to the outputs of a DIO or DO module:
+// PWM duty cycles and output diagnostics.
+type DigitalOutputs struct {
+	ctrl   *RevPiControl
+	device Device
+}
+
+// NewDigitalOutputs returns the outputs of the DIO or DO module at address.
+func NewDigitalOutputs(c *RevPiControl, address uint8) (*DigitalOutputs, error) {
+	d, err := c.DeviceByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if !hasDigitalOutputs(d) {
+		return nil, fmt.Errorf("%s has no digital outputs", d)
+	}
+	return &DigitalOutputs{ctrl: c, device: d}, nil
+}
+
+// Device returns the description of the module.
+func (o *DigitalOutputs) Device() Device {
+	return o.device
+}
+
+// SetPWM sets the duty cycle of output n (1-16) in percent, from 0 to 100.
+// PWM must be enabled for the output in piCtory (OutputPWMActive), otherwise the module ignores the value.
+func (o *DigitalOutputs) SetPWM(n int, percent uint8) error {
+	offset, err := o.pwmOffset(n)
+	if err != nil {
+		return err
+	}
+	if percent > 100 {
+		return fmt.Errorf("invalid duty cycle %d%% for output %d", percent, n)
+	}
+	tx := o.ctrl.Begin()
+	if err = tx.SetBytes(offset, []byte{percent}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PWM reads the duty cycle of output n (1-16) in percent.
+func (o *DigitalOutputs) PWM(n int) (uint8, error) {
+	offset, err := o.pwmOffset(n)
+	if err != nil {
+		return 0, err
+	}
+	var b [1]byte
+	if _, err = o.ctrl.Read(offset, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (o *DigitalOutputs) pwmOffset(n int) (uint32, error) {
+	if t, ok := LookupModuleType(o.device.ModuleType); !ok || !t.Has(CapPWM) {
+		return 0, fmt.Errorf("%s has no PWM outputs", o.device)
+	}
+	if n < 1 || n > dioChannels {
+		return 0, fmt.Errorf("invalid output %d", n)
+	}
+	if o.device.Output.Length < dioPWMOffset+dioChannels {
+		return 0, fmt.Errorf("%s has no PWM outputs in the process image", o.device)
+	}
+	return o.device.Output.Offset + dioPWMOffset + uint32(n-1), nil
+}
+
+// OutputStatus reads the output status word, bit n is set if output n+1 has a fault.
+func (o *DigitalOutputs) OutputStatus() (uint16, error) {
+	var b [2]byte
+	n, err := o.ctrl.Read(o.device.Input.Offset+dioOutputStatusOffset, b[:])
+	if err != nil {
+		return 0, err
+	}
+	if n != len(b) {
+		return 0, fmt.Errorf("could not read the output status of %s: %d of %d bytes read", o.device, n, len(b))
+	}
+	return uint16(b[0]) | uint16(b[1])<<8, nil
+}
+
+// CheckOutputs returns OutputFaults if some outputs have a fault, nil if all are fine.
+func (o *DigitalOutputs) CheckOutputs() error {
+	status, err := o.OutputStatus()
+	if err != nil {
+		return err
+	}
+	if faults := DecodeOutputFaults(o.device.Address, status); faults != nil {
+		return faults
+	}
+	return nil
+}
+
+// OutputFaultEvent is a change of the fault state of an output,
+// or a failure to read the output status if Error is set.
+type OutputFaultEvent struct {
+	Address uint8 // address of the module
+	Output  int   // output number, from 1 to 16
+	Fault   bool  // true if the fault appeared, false if it cleared
+	Time    time.Time
+	Error   error // error reading the output status, Address, Output and Fault are not set
+}
+
+// Err returns the read error, or the fault as *OutputFault, nil if the fault cleared.
+func (e OutputFaultEvent) Err() error {
+	if e.Error != nil {
+		return e.Error
+	}
+	if !e.Fault {
+		return nil
+	}
+	return &OutputFault{Address: e.Address, Output: e.Output}
+}
+
+func (e OutputFaultEvent) String() string {
+	if e.Error != nil {
+		return fmt.Sprintf("could not read the output status: %v", e.Error)
+	}
+	if e.Fault {
+		return e.Err().Error()
+	}
+	return fmt.Sprintf("output %d of the module at address %d recovered", e.Output, e.Address)
+}
+
+// WatchOutputFaults polls the output status of all DIO and DO modules every period and delivers
+// an event on the returned channel each time an output fault appears or clears,
+// starting with the faults present when it is called.
+// A read error is delivered as an event with Error set when polling starts failing,
+// and retried at the next period. The channel is closed when ctx is done.
+func (c *RevPiControl) WatchOutputFaults(ctx context.Context, period time.Duration) <-chan OutputFaultEvent {
+	ch := make(chan OutputFaultEvent, dioChannels)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+
+		last := map[uint8]uint16{}
+		failing := false
+		for {
+			events, err := c.outputFaultChanges(last)
+			if err != nil && !failing {
+				events = append(events, OutputFaultEvent{Time: time.Now(), Error: err})
+			}
+			failing = err != nil
+			for _, e := range events {
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return ch
+}
+
+// outputFaultChanges reads the output status of the active DIO and DO modules
+// and returns the changes since the status in last, which is updated.
+// The changes of the modules read before an error are returned with it.
+func (c *RevPiControl) outputFaultChanges(last map[uint8]uint16) ([]OutputFaultEvent, error) {
+	devices, err := c.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []OutputFaultEvent
+	for _, d := range devices {
+		if !hasDigitalOutputs(d) || d.State != DeviceActive || d.Input.Length < dioOutputStatusOffset+2 {
+			continue
+		}
+		status, err := (&DigitalOutputs{ctrl: c, device: d}).OutputStatus()
+		if err != nil {
+			return events, err
+		}
+		now := time.Now()
+		changed := status ^ last[d.Address]
+		last[d.Address] = status
+		for n := 0; n < dioChannels; n++ {
+			if changed&(1<<n) != 0 {
+				events = append(events, OutputFaultEvent{Address: d.Address, Output: n + 1, Fault: status&(1<<n) != 0, Time: now})
+			}
+		}
+	}
+	return events, nil
+}
+
+// hasDigitalOutputs reports whether d is an IO module with digital outputs, a DIO or a DO.
+func hasDigitalOutputs(d Device) bool {
+	t, ok := LookupModuleType(d.ModuleType)
+	return ok && t.Has(CapDigitalOutputs) && t.Family == FamilyIO
+}
@@ -0,0 +1,56 @@
+package gopicontrol
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func nextFaultEvent(t *testing.T, ch <-chan OutputFaultEvent) OutputFaultEvent {
+	t.Helper()
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no output fault event")
+	}
+	return OutputFaultEvent{}
+}
+
+func TestWatchOutputFaults(t *testing.T) {
+	fake := newFakeBackend()
+	c := NewRevPiControlWithBackend(fake)
+	// output status of the DIO at input offset 4: fault on output 2
+	if _, err := c.Write(4, []byte{0x02, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := c.WatchOutputFaults(ctx, 5*time.Millisecond)
+
+	e := nextFaultEvent(t, ch)
+	if e.Address != 32 || e.Output != 2 || !e.Fault || !errors.Is(e.Err(), ErrOutputFault) {
+		t.Fatalf("got %v, want a fault on output 2", e)
+	}
+
+	fake.mu.Lock()
+	fake.readErr = &DriverError{Op: "read", Errno: syscall.EIO}
+	fake.mu.Unlock()
+	e = nextFaultEvent(t, ch)
+	var derr *DriverError
+	if !errors.As(e.Err(), &derr) || derr.Errno != syscall.EIO {
+		t.Fatalf("got %v, want the read error", e)
+	}
+
+	fake.mu.Lock()
+	fake.readErr = nil
+	fake.image[4] = 0
+	fake.mu.Unlock()
+	e = nextFaultEvent(t, ch)
+	if e.Output != 2 || e.Fault || e.Err() != nil {
+		t.Fatalf("got %v, want output 2 recovered", e)
+	}
+}
@@ -0,0 +1,127 @@
+package gopicontrol
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// Sentinel errors, test for them with errors.Is.
+var (
+	// ErrVariableNotFound is returned when a variable name is not part of the configuration.
+	ErrVariableNotFound = errors.New("could not find variable")
+	// ErrNotOpen is returned when the piControl device cannot be opened.
+	ErrNotOpen = errors.New("piControl device not open")
+	// ErrDeviceNotFound is returned when no device is configured at an address.
+	ErrDeviceNotFound = errors.New("could not find device")
+	// ErrOutputFault is wrapped by the faults reported by DIO and DO modules on their outputs, see OutputFault.
+	ErrOutputFault = errors.New("output fault")
+)
+
+// deviceNotFound returns an error wrapping ErrDeviceNotFound for the device address.
+func deviceNotFound(address uint8) error {
+	return fmt.Errorf("%w at address %d", ErrDeviceNotFound, address)
+}
+
+// variableNotFound returns an error wrapping ErrVariableNotFound for the variable name.
+func variableNotFound(name string) error {
+	return fmt.Errorf("%w %s", ErrVariableNotFound, name)
+}
+
+// ConfigError is a configuration error reported by the driver, when the connected modules
+// do not match the piCtory configuration (PICONTROL_CONFIG_ERROR_WRONG_*).
+type ConfigError int
+
+// Configuration errors of the driver.
+const (
+	ErrConfigWrongModuleType   ConfigError = PICONTROL_CONFIG_ERROR_WRONG_MODULE_TYPE
+	ErrConfigWrongInputLength  ConfigError = PICONTROL_CONFIG_ERROR_WRONG_INPUT_LENGTH
+	ErrConfigWrongOutputLength ConfigError = PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_LENGTH
+	ErrConfigWrongConfigLength ConfigError = PICONTROL_CONFIG_ERROR_WRONG_CONFIG_LENGTH
+	ErrConfigWrongInputOffset  ConfigError = PICONTROL_CONFIG_ERROR_WRONG_INPUT_OFFSET
+	ErrConfigWrongOutputOffset ConfigError = PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_OFFSET
+	ErrConfigWrongConfigOffset ConfigError = PICONTROL_CONFIG_ERROR_WRONG_CONFIG_OFFSET
+)
+
+func (e ConfigError) Error() string {
+	switch e {
+	case ErrConfigWrongModuleType:
+		return "wrong module type"
+	case ErrConfigWrongInputLength:
+		return "wrong input length"
+	case ErrConfigWrongOutputLength:
+		return "wrong output length"
+	case ErrConfigWrongConfigLength:
+		return "wrong config length"
+	case ErrConfigWrongInputOffset:
+		return "wrong input offset"
+	case ErrConfigWrongOutputOffset:
+		return "wrong output offset"
+	case ErrConfigWrongConfigOffset:
+		return "wrong config offset"
+	default:
+		return fmt.Sprintf("configuration error %d", int(e))
+	}
+}
+
+// isConfigError reports whether code is one of the PICONTROL_CONFIG_ERROR_WRONG_* codes.
+func isConfigError(code int) bool {
+	return code <= PICONTROL_CONFIG_ERROR_WRONG_MODULE_TYPE && code >= PICONTROL_CONFIG_ERROR_WRONG_CONFIG_OFFSET
+}
+
+// DriverError is a failed call to the piControl driver.
+// Either Errno is set, if the system call failed, or Code, if the driver returned a negative result.
+// It unwraps to the Errno or, for the PICONTROL_CONFIG_ERROR_WRONG_* codes, to a ConfigError, e.g.:
+//
+//	var derr *DriverError
+//	if errors.As(err, &derr) { ... }
+//	if errors.Is(err, syscall.ENOENT) { ... }
+//	if errors.Is(err, ErrConfigWrongModuleType) { ... }
+type DriverError struct {
+	Op      string        // driver call, e.g. "KB_FIND_VARIABLE" or "open"
+	Errno   syscall.Errno // errno of the failed system call, 0 if none
+	Code    int           // negative result returned by the driver, 0 if none
+	Message string        // message of the driver (KB_GET_LAST_MESSAGE), if any
+}
+
+func (e *DriverError) Error() string {
+	s := "piControl " + e.Op + ": "
+	switch {
+	case e.Errno != 0:
+		s += e.Errno.Error()
+	case isConfigError(e.Code):
+		s += ConfigError(e.Code).Error()
+	default:
+		s += fmt.Sprintf("error %d", e.Code)
+	}
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+	return s
+}
+
+// Unwrap returns the Errno or the ConfigError of the call.
+func (e *DriverError) Unwrap() error {
+	switch {
+	case e.Errno != 0:
+		return e.Errno
+	case isConfigError(e.Code):
+		return ConfigError(e.Code)
+	}
+	return nil
+}
+
+// Is makes a failed open match ErrNotOpen.
+func (e *DriverError) Is(target error) bool {
+	return target == ErrNotOpen && e.Op == "open"
+}
+
+// newDriverError returns a DriverError for op from a system call error.
+// Errors other than errnos are returned unchanged.
+func newDriverError(op string, err error) error {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return err
+	}
+	return &DriverError{Op: op, Errno: errno}
+}
@@ -0,0 +1,27 @@
+package gopicontrol
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestReadIntoVariableNotFound(t *testing.T) {
+	c := NewRevPiControlWithBackend(newFakeBackend())
+	var s struct {
+		Missing bool `revpi:"Missing"`
+	}
+	if err := c.ReadInto(&s); !errors.Is(err, ErrVariableNotFound) {
+		t.Fatalf("got %v, want ErrVariableNotFound", err)
+	}
+}
+
+func TestDownloadGatewayConfigDriverError(t *testing.T) {
+	c := NewRevPiControlWithBackend(newFakeBackend())
+	err := c.DownloadGatewayConfig(strings.NewReader("config"), GatewayRight)
+	var derr *DriverError
+	if !errors.As(err, &derr) || derr.Errno != syscall.EOPNOTSUPP {
+		t.Fatalf("got %v, want a DriverError with EOPNOTSUPP", err)
+	}
+}
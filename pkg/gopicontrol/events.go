@@ -0,0 +1,91 @@
+package gopicontrol
+
+import (
+	"context"
+	"fmt"
+)
+
+// Event is an event signalled by the piControl driver.
+type Event int
+
+// Events of the driver.
+const (
+	// EventReset is signalled after the driver has been reset, e.g. by piCtory after a configuration change.
+	EventReset Event = KB_EVENT_RESET
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("event %d", int(e))
+	}
+}
+
+// pendingEvent is a wait for a driver event in progress, shared by all callers of WaitEvent.
+type pendingEvent struct {
+	done  chan struct{}
+	event Event
+	err   error
+}
+
+// WaitEvent waits for the next event of the driver or for ctx to be done.
+// The driver call cannot be interrupted: when ctx is done the wait continues in the background
+// and its event is returned by the next call of WaitEvent, so that no reset is missed.
+// Concurrent calls share the same wait and get the same event.
+func (c *RevPiControl) WaitEvent(ctx context.Context) (Event, error) {
+	c.eventMu.Lock()
+	p := c.event
+	if p == nil {
+		p = &pendingEvent{done: make(chan struct{})}
+		c.event = p
+		go func() {
+			ev, err := c.backend.WaitForEvent()
+			p.event, p.err = Event(ev), err
+			close(p.done)
+		}()
+	}
+	c.eventMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-p.done:
+	}
+
+	c.eventMu.Lock()
+	if c.event == p {
+		c.event = nil
+	}
+	c.eventMu.Unlock()
+	return p.event, p.err
+}
+
+// Events returns a channel delivering the events of the driver until ctx is done.
+// On a reset the cached variable table and struct bindings are discarded and the device list
+// and variables are read again before the event is delivered.
+// The channel is closed when ctx is done or waiting for an event fails.
+func (c *RevPiControl) Events(ctx context.Context) <-chan Event {
+	ch := make(chan Event, 1)
+	go func() {
+		defer close(ch)
+		for {
+			ev, err := c.WaitEvent(ctx)
+			if err != nil {
+				return
+			}
+			if ev == EventReset {
+				c.invalidateCaches()
+				// errors are reported again by the next lookup
+				c.variables()
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
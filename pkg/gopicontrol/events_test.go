@@ -0,0 +1,149 @@
+package gopicontrol_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+	"github.com/mezzato/revpi/pkg/pisim"
+)
+
+// waitTimeout bounds the waits of the event tests.
+const waitTimeout = 5 * time.Second
+
+func TestWaitEventCancel(t *testing.T) {
+	c, b := newSimControl(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.WaitEvent(ctx)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(waitTimeout):
+		t.Fatal("WaitEvent did not return after the cancellation")
+	}
+
+	// the reset signalled after the cancellation is returned by the next wait
+	if err := b.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	if ev, err := c.WaitEvent(ctx); err != nil || ev != gopicontrol.EventReset {
+		t.Errorf("got %v, %v, want the reset", ev, err)
+	}
+}
+
+func TestWaitEventShared(t *testing.T) {
+	c, b := newSimControl(t)
+
+	// start the driver wait
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.WaitEvent(cancelled)
+
+	const waiters = 3
+	events := make(chan gopicontrol.Event, waiters)
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	for i := 0; i < waiters; i++ {
+		go func() {
+			ev, err := c.WaitEvent(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			events <- ev
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := b.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < waiters; i++ {
+		if ev := <-events; ev != gopicontrol.EventReset {
+			t.Errorf("waiter got %v, want the reset", ev)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.eventWaits != 1 {
+		t.Errorf("got %d driver waits, want 1", b.eventWaits)
+	}
+}
+
+func TestEventsInvalidateCaches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.rsc")
+	if err := os.WriteFile(path, []byte(simConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sim, err := pisim.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := gopicontrol.NewRevPiControlWithBackend(sim)
+
+	type spare struct {
+		Spare uint16 `revpi:"Spare"`
+	}
+	if _, err = c.GetVariableInfo("Spare"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.ReadInto(&spare{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.ListVariables(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+	events := c.Events(ctx)
+	time.Sleep(20 * time.Millisecond)
+
+	// piCtory renames the variable and resets the driver
+	if err = os.WriteFile(path, []byte(strings.Replace(simConfig, `"Spare"`, `"Reserve"`, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = sim.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if ev != gopicontrol.EventReset {
+			t.Fatalf("got %v, want the reset", ev)
+		}
+	case <-ctx.Done():
+		t.Fatal("no reset event")
+	}
+
+	if _, err = c.GetVariableInfo("Spare"); !errors.Is(err, gopicontrol.ErrVariableNotFound) {
+		t.Errorf("got %v for the renamed variable, want ErrVariableNotFound", err)
+	}
+	if err = c.ReadInto(&spare{}); !errors.Is(err, gopicontrol.ErrVariableNotFound) {
+		t.Errorf("got %v binding the renamed variable, want ErrVariableNotFound", err)
+	}
+	vars, err := c.ListVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, v := range vars {
+		found = found || v.Name == "Reserve"
+	}
+	if !found {
+		t.Error("the reloaded variables do not contain Reserve")
+	}
+}
@@ -0,0 +1,206 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ExportedImage is an application-local copy of the process image used to maintain exported outputs,
+// the output entries flagged with EntryExported in the configuration.
+// Values are set in the local copy and pushed by Commit.
+//
+// An ExportedImage only writes the outputs set through it, the owned outputs: the other exported
+// outputs, including those maintained by other programs, are left untouched. Several ExportedImage
+// objects and programs can therefore each own a subset of the exported outputs.
+// An ExportedImage is not safe for concurrent use.
+type ExportedImage struct {
+	ctrl    *RevPiControl
+	data    []byte
+	owned   []byte // bits set through this image
+	outputs map[string]*Variable
+}
+
+// ExportedOutputs returns the exported output variables of the process image.
+func (c *RevPiControl) ExportedOutputs() ([]Variable, error) {
+	vars, err := c.variables()
+	if err != nil {
+		return nil, err
+	}
+	var outputs []Variable
+	for _, v := range vars.list {
+		if v.Type == EntryOutput && v.Exported {
+			outputs = append(outputs, v)
+		}
+	}
+	return outputs, nil
+}
+
+// NewExportedImage creates a local image of the exported outputs initialized with the current process image.
+func (c *RevPiControl) NewExportedImage() (*ExportedImage, error) {
+	outputs, err := c.ExportedOutputs()
+	if err != nil {
+		return nil, err
+	}
+	img := &ExportedImage{
+		ctrl:    c,
+		data:    make([]byte, ProcessImageLength),
+		owned:   make([]byte, ProcessImageLength),
+		outputs: make(map[string]*Variable, len(outputs)),
+	}
+	for i := range outputs {
+		img.outputs[outputs[i].Name] = &outputs[i]
+	}
+	if err = img.readImage(img.data); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Outputs returns the exported outputs sorted by offset.
+func (img *ExportedImage) Outputs() []Variable {
+	outputs := make([]Variable, 0, len(img.outputs))
+	for _, v := range img.outputs {
+		outputs = append(outputs, *v)
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		if outputs[i].Offset != outputs[j].Offset {
+			return outputs[i].Offset < outputs[j].Offset
+		}
+		return outputs[i].Bit < outputs[j].Bit
+	})
+	return outputs
+}
+
+// SetBool sets a 1 bit exported output in the local image.
+func (img *ExportedImage) SetBool(name string, v bool) error { return exportedSet(img, name, v) }
+
+// SetUint8 sets an unsigned 8 bit exported output in the local image.
+func (img *ExportedImage) SetUint8(name string, v uint8) error { return exportedSet(img, name, v) }
+
+// SetInt8 sets a signed 8 bit exported output in the local image.
+func (img *ExportedImage) SetInt8(name string, v int8) error { return exportedSet(img, name, v) }
+
+// SetUint16 sets an unsigned 16 bit exported output in the local image.
+func (img *ExportedImage) SetUint16(name string, v uint16) error { return exportedSet(img, name, v) }
+
+// SetInt16 sets a signed 16 bit exported output in the local image.
+func (img *ExportedImage) SetInt16(name string, v int16) error { return exportedSet(img, name, v) }
+
+// SetUint32 sets an unsigned 32 bit exported output in the local image.
+func (img *ExportedImage) SetUint32(name string, v uint32) error { return exportedSet(img, name, v) }
+
+// SetInt32 sets a signed 32 bit exported output in the local image.
+func (img *ExportedImage) SetInt32(name string, v int32) error { return exportedSet(img, name, v) }
+
+// Value returns the unsigned value of an exported output in the local image.
+func (img *ExportedImage) Value(name string) (uint32, error) {
+	v, err := img.output(name)
+	if err != nil {
+		return 0, err
+	}
+	if v.IsBit() {
+		return uint32(img.data[v.Offset]>>v.Bit) & 1, nil
+	}
+	return leUint32(img.data[uint32(v.Offset) : uint32(v.Offset)+variableSize(v)]), nil
+}
+
+// Owned reports whether the exported output has been set through this image.
+func (img *ExportedImage) Owned(name string) bool {
+	v, ok := img.outputs[name]
+	if !ok {
+		return false
+	}
+	if v.IsBit() {
+		return img.owned[v.Offset]&(1<<v.Bit) != 0
+	}
+	return img.owned[v.Offset] != 0
+}
+
+// Commit pushes the owned outputs to the process image: bit outputs with one KB_SET_VALUE call each,
+// which the driver performs atomically, and the other outputs merged into contiguous ranges
+// with one Write call per range.
+func (img *ExportedImage) Commit() error {
+	// serialize with the commits of transactions
+	img.ctrl.txMu.Lock()
+	defer img.ctrl.txMu.Unlock()
+
+	type byteRange struct{ start, end uint32 }
+	var ranges []byteRange
+	for _, v := range img.Outputs() {
+		if !img.Owned(v.Name) {
+			continue
+		}
+		if v.IsBit() {
+			val := SPIValue{I16uAddress: v.Offset, I8uBit: v.Bit, I8uValue: img.data[v.Offset] >> v.Bit & 1}
+			if err := img.ctrl.SetBitValue(&val); err != nil {
+				return err
+			}
+			continue
+		}
+		start := uint32(v.Offset)
+		end := start + variableSize(&v)
+		if n := len(ranges); n > 0 && ranges[n-1].end == start {
+			ranges[n-1].end = end
+			continue
+		}
+		ranges = append(ranges, byteRange{start, end})
+	}
+
+	for _, r := range ranges {
+		n, err := img.ctrl.Write(r.start, img.data[r.start:r.end])
+		if err != nil {
+			return err
+		}
+		if n != int(r.end-r.start) {
+			return fmt.Errorf("could not write %d bytes at offset %d: %d bytes written", r.end-r.start, r.start, n)
+		}
+	}
+	return nil
+}
+
+// readImage reads the whole process image into data.
+func (img *ExportedImage) readImage(data []byte) error {
+	n, err := img.ctrl.Read(0, data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("could not read %d bytes at offset 0: %d bytes read", len(data), n)
+	}
+	return nil
+}
+
+func (img *ExportedImage) output(name string) (*Variable, error) {
+	v, ok := img.outputs[name]
+	if !ok {
+		return nil, fmt.Errorf("variable %s is not an exported output", name)
+	}
+	if !supportedLength(v.BitLength) {
+		return nil, fmt.Errorf("variable %s has an unsupported length of %d bits", name, v.BitLength)
+	}
+	return v, nil
+}
+
+// exportedSet sets v in the local image and marks the variable as owned.
+func exportedSet[T Value](img *ExportedImage, name string, v T) error {
+	info, err := img.output(name)
+	if err != nil {
+		return err
+	}
+	if bits := valueBits[T](); int(info.BitLength) != bits {
+		return fmt.Errorf("variable %s has %d bits and cannot be set as %T (%d bits)", name, info.BitLength, v, bits)
+	}
+
+	raw := encodeValue(v)
+	if info.IsBit() {
+		mask := byte(1) << info.Bit
+		img.data[info.Offset] = img.data[info.Offset]&^mask | byte(raw&1)<<info.Bit
+		img.owned[info.Offset] |= mask
+		return nil
+	}
+	for i := uint32(0); i < variableSize(info); i++ {
+		img.data[uint32(info.Offset)+i] = byte(raw >> (8 * i))
+		img.owned[uint32(info.Offset)+i] = 0xff
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package gopicontrol_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportedImageOwnership(t *testing.T) {
+	c, _ := newSimControl(t)
+	img, err := c.NewExportedImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, v := range img.Outputs() {
+		names = append(names, v.Name)
+	}
+	if got, want := strings.Join(names, " "), "O_1 O_2 PWM_1 Value"; got != want {
+		t.Fatalf("got exported outputs %s, want %s", got, want)
+	}
+
+	if v, err := img.Value("Value"); err != nil || v != 500 {
+		t.Errorf("got Value %d, %v, want the current value 500", v, err)
+	}
+	if img.Owned("O_2") || img.Owned("Value") {
+		t.Error("outputs are owned before being set")
+	}
+	if err = img.SetBool("O_2", true); err != nil {
+		t.Fatal(err)
+	}
+	if err = img.SetUint16("Value", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if !img.Owned("O_2") || !img.Owned("Value") {
+		t.Error("set outputs are not owned")
+	}
+	if img.Owned("O_1") || img.Owned("PWM_1") {
+		t.Error("outputs sharing a byte with an owned output are owned")
+	}
+}
+
+func TestExportedImageRejectsOtherEntries(t *testing.T) {
+	c, b := newSimControl(t)
+	img, err := c.NewExportedImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"O_3", "Spare", "I_1", "Missing"} {
+		if err := img.SetUint16(name, 1); err == nil {
+			t.Errorf("setting %s succeeded", name)
+		}
+		if img.Owned(name) {
+			t.Errorf("%s is owned", name)
+		}
+	}
+	if err := img.SetUint8("Value", 1); err == nil {
+		t.Error("setting the 16 bit Value as uint8 succeeded")
+	}
+
+	if err = img.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, writes, setBit := b.counts(); writes != 0 || setBit != 0 {
+		t.Errorf("commit without owned outputs made %d writes and %d bit sets", writes, setBit)
+	}
+}
+
+func TestExportedImageCommit(t *testing.T) {
+	c, b := newSimControl(t)
+	img, err := c.NewExportedImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, set := range []func() error{
+		func() error { return img.SetBool("O_2", true) },
+		func() error { return img.SetUint8("PWM_1", 40) },
+		func() error { return img.SetUint16("Value", 1000) },
+	} {
+		if err = set(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// another program changes outputs after the image was read
+	setValue(t, b, "O_1", 0)
+	setValue(t, b, "O_3", 1)
+	setValue(t, b, "Spare", 7)
+	b.counts()
+
+	if err = img.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	reads, writes, setBit := b.counts()
+	if reads != 0 || writes != 1 || setBit != 1 {
+		t.Errorf("got %d reads, %d writes and %d bit sets, want 0, 1 and 1", reads, writes, setBit)
+	}
+
+	for name, want := range map[string]uint32{
+		"O_1": 0, "O_2": 1, "O_3": 1, "PWM_1": 40, "Value": 1000, "Spare": 7,
+	} {
+		if got := value(t, b, name); got != want {
+			t.Errorf("got %s %d, want %d", name, got, want)
+		}
+	}
+}
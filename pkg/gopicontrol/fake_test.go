@@ -0,0 +1,167 @@
+package gopicontrol
+
+import (
+	"sync"
+	"syscall"
+)
+
+// fakeBackend is an in-memory Backend with a DIO at address 32 with the variables
+// I_1 (bit 0 of offset 0), I_2 (bit 1 of offset 0), Status (16 bit at offset 2),
+// O_1 (bit 0 of offset 8) and Value (32 bit at offset 10),
+// and a RevPi Core at address 0 with RevPiLED (8 bit at offset 20).
+type fakeBackend struct {
+	mu       sync.Mutex
+	image    [ProcessImageLength]byte
+	resets   int
+	lookups  int
+	onLookup func() // called by GetVariableInfo without the lock, if set
+	readErr  error  // returned by Read, if set
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{}
+}
+
+var fakeDevice = SDeviceInfo{
+	I8uAddress:       32,
+	I8uActive:        1,
+	I16uModuleType:   96,
+	I16uInputOffset:  0,
+	I16uInputLength:  8,
+	I16uOutputOffset: 8,
+	I16uOutputLength: 6,
+	I16uEntries:      5,
+}
+
+var fakeCore = SDeviceInfo{
+	I8uAddress:       0,
+	I8uActive:        1,
+	I16uModuleType:   95,
+	I16uInputOffset:  20,
+	I16uOutputOffset: 20,
+	I16uOutputLength: 1,
+	I16uFirstEntry:   5,
+	I16uEntries:      1,
+}
+
+var fakeEntries = []SEntryInfo{
+	{I8uAddress: 32, I8uType: uint8(EntryInput), I16uIndex: 0, I16uBitLength: 1, I8uBitPos: 0, I16uOffset: 0, StrVarName: ByteToUint8Array([]byte("I_1"))},
+	{I8uAddress: 32, I8uType: uint8(EntryInput), I16uIndex: 1, I16uBitLength: 1, I8uBitPos: 1, I16uOffset: 0, StrVarName: ByteToUint8Array([]byte("I_2"))},
+	{I8uAddress: 32, I8uType: uint8(EntryInput), I16uIndex: 2, I16uBitLength: 16, I16uOffset: 2, StrVarName: ByteToUint8Array([]byte("Status"))},
+	{I8uAddress: 32, I8uType: uint8(EntryOutput), I16uIndex: 0, I16uBitLength: 1, I8uBitPos: 0, I16uOffset: 8, StrVarName: ByteToUint8Array([]byte("O_1"))},
+	{I8uAddress: 32, I8uType: uint8(EntryOutput), I16uIndex: 1, I16uBitLength: 32, I16uOffset: 10, StrVarName: ByteToUint8Array([]byte("Value"))},
+	{I8uAddress: 0, I8uType: uint8(EntryOutput), I16uIndex: 0, I16uBitLength: 8, I16uOffset: 20, StrVarName: ByteToUint8Array([]byte(LEDVariable))},
+}
+
+func (f *fakeBackend) Open() error  { return nil }
+func (f *fakeBackend) Close() error { return nil }
+
+func (f *fakeBackend) Reset() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resets++
+	return nil
+}
+
+func (f *fakeBackend) Read(offset uint32, data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.readErr != nil {
+		return 0, f.readErr
+	}
+	if offset >= ProcessImageLength {
+		return 0, &DriverError{Op: "read", Errno: syscall.EINVAL}
+	}
+	return copy(data, f.image[offset:]), nil
+}
+
+func (f *fakeBackend) Write(offset uint32, data []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if offset >= ProcessImageLength {
+		return 0, &DriverError{Op: "write", Errno: syscall.EINVAL}
+	}
+	return copy(f.image[offset:], data), nil
+}
+
+func (f *fakeBackend) GetDeviceInfo(devInfo *SDeviceInfo) (int, error) {
+	for _, d := range []SDeviceInfo{fakeCore, fakeDevice} {
+		if d.I8uAddress == devInfo.I8uAddress {
+			*devInfo = d
+			return 0, nil
+		}
+	}
+	return 0, &DriverError{Op: "KB_GET_DEVICE_INFO", Errno: syscall.ENXIO}
+}
+
+func (f *fakeBackend) GetDeviceInfoList() ([]SDeviceInfo, error) {
+	return []SDeviceInfo{fakeCore, fakeDevice}, nil
+}
+
+func (f *fakeBackend) GetEntryInfoList() ([]SEntryInfo, error) {
+	return append([]SEntryInfo(nil), fakeEntries...), nil
+}
+
+func (f *fakeBackend) GetBitValue(v *SPIValue) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v.I8uValue = f.image[v.I16uAddress] >> (v.I8uBit % 8) & 1
+	return nil
+}
+
+func (f *fakeBackend) SetBitValue(v *SPIValue) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mask := byte(1) << (v.I8uBit % 8)
+	if v.I8uValue != 0 {
+		f.image[v.I16uAddress] |= mask
+	} else {
+		f.image[v.I16uAddress] &^= mask
+	}
+	return nil
+}
+
+func (f *fakeBackend) GetVariableInfo(name string) (*SPIVariable, error) {
+	f.mu.Lock()
+	f.lookups++
+	onLookup := f.onLookup
+	f.mu.Unlock()
+	if onLookup != nil {
+		onLookup()
+	}
+
+	for _, e := range fakeEntries {
+		if EntryName(&e) == name {
+			return &SPIVariable{
+				StrVarName:  e.StrVarName,
+				I16uAddress: e.I16uOffset,
+				I8uBit:      e.I8uBitPos,
+				I16uLength:  e.I16uBitLength,
+			}, nil
+		}
+	}
+	return nil, variableNotFound(name)
+}
+
+func (f *fakeBackend) SetExportedOutputs(image []byte) error {
+	return errNotSupported("KB_SET_EXPORTED_OUTPUTS")
+}
+func (f *fakeBackend) ResetCounter(address uint8, bitfield uint16) (int, error) {
+	return 0, errNotSupported("KB_DIO_RESET_COUNTER")
+}
+func (f *fakeBackend) SetOutputWatchdog(timeoutMs uint32) error {
+	return errNotSupported("KB_SET_OUTPUT_WATCHDOG")
+}
+func (f *fakeBackend) StopIO(mode int) (bool, error)      { return false, errNotSupported("KB_STOP_IO") }
+func (f *fakeBackend) ConfigStop() error                  { return nil }
+func (f *fakeBackend) ConfigSend(data *SConfigData) error { return errNotSupported("KB_CONFIG_SEND") }
+func (f *fakeBackend) ConfigStart() error                 { return nil }
+func (f *fakeBackend) WaitForEvent() (int, error)         { return 0, errNotSupported("KB_WAIT_FOR_EVENT") }
+func (f *fakeBackend) LastMessage() (string, error)       { return "", nil }
+func (f *fakeBackend) UpdateFirmware(addrP uint32) (int, error) {
+	return 0, errNotSupported("KB_UPDATE_DEVICE_FIRMWARE")
+}
+
+func errNotSupported(op string) error {
+	return &DriverError{Op: op, Errno: syscall.EOPNOTSUPP}
+}
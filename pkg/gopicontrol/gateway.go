@@ -0,0 +1,73 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"io"
+)
+
+// GatewaySide selects the master gateway connected on the left or on the right of the RevPi Core.
+type GatewaySide int
+
+// Gateway sides.
+const (
+	GatewayRight GatewaySide = iota
+	GatewayLeft
+)
+
+func (s GatewaySide) String() string {
+	if s == GatewayLeft {
+		return "left"
+	}
+	return "right"
+}
+
+// DownloadGatewayConfig downloads the configuration read from r to the master gateway on side.
+// The I/O communication is stopped during the download and the payload is sent in chunks of CONFIG_DATA_SIZE bytes.
+// On error the download is aborted and the I/O communication restarted, so that the gateway keeps running.
+// A communication stopped with StopIO before the download is running afterwards.
+func (c *RevPiControl) DownloadGatewayConfig(r io.Reader, side GatewaySide) (err error) {
+	c.ioMu.Lock()
+	defer c.ioMu.Unlock()
+
+	if err = c.backend.ConfigStop(); err != nil {
+		return err
+	}
+	defer func() {
+		// restart the I/O communication in any case, the error of the download takes precedence
+		startErr := c.backend.ConfigStart()
+		if startErr == nil {
+			c.ioStopped = false
+		}
+		if startErr != nil && err == nil {
+			err = startErr
+		} else if startErr != nil {
+			err = fmt.Errorf("%w, restart of the I/O communication failed too: %v", err, startErr)
+		}
+	}()
+
+	var data SConfigData
+	if side == GatewayLeft {
+		data.BLeft = 1
+	}
+	var total int
+	for {
+		n, rerr := io.ReadFull(r, data.AcData[:])
+		if n > 0 {
+			data.I16uLen = uint16(n)
+			if err = c.backend.ConfigSend(&data); err != nil {
+				return fmt.Errorf("could not send the configuration to the %v gateway at byte %d: %w", side, total, err)
+			}
+			total += n
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return fmt.Errorf("could not read the gateway configuration at byte %d: %w", total, rerr)
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("the gateway configuration is empty")
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package gopicontrol_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestDownloadGatewayConfigRestartsIO(t *testing.T) {
+	c, b := newSimControl(t)
+	if err := c.StopIO(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DownloadGatewayConfig(strings.NewReader("config"), gopicontrol.GatewayRight); err != nil {
+		t.Fatal(err)
+	}
+	if c.IOStopped() || b.IOStopped() {
+		t.Errorf("got I/O stopped %t, simulator %t after the download, want running", c.IOStopped(), b.IOStopped())
+	}
+	if got := string(b.GatewayConfig(false)); got != "config" {
+		t.Errorf("got gateway configuration %q, want %q", got, "config")
+	}
+}
@@ -2,16 +2,18 @@ package gopicontrol
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
-	"os"
-	"syscall"
-
-	"unsafe"
-
-	"golang.org/x/sys/unix"
+	"math"
+	"reflect"
+	"sync"
+	"time"
 )
 
+// ProcessImageLength is the size in bytes of the piControl process image (KB_PI_LEN in the kernel module).
+const ProcessImageLength = 4096
+
 // ByteToUint8Array converts a byte slice to a uint8 array.
 func ByteToUint8Array(s []byte) (r [32]uint8) {
 	for i, c := range s {
@@ -31,201 +33,109 @@ func NumToBytes(num interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// see /home/enrico/go_src/src/golang.org/x/sys/unix/zsyscall_linux_arm64.go
-// https://github.com/golang/crypto/blob/master/ssh/terminal/util.go
+// RevPiControl is an object giving access to the piControl process image.
+// By default it uses the piControl driver file descriptor, see NewRevPiControlWithBackend to use another backend.
+// RevPiControl is safe for concurrent use by multiple goroutines if its backend is, as DeviceBackend
+// and the pisim simulator are.
+type RevPiControl struct {
+	cycle uint64 // snapshot counter, first field for 64-bit alignment of atomic operations on ARM
 
-// Do the interface allocations only once for common
-// Errno values.
-var (
-	errEAGAIN error = syscall.EAGAIN
-	errEINVAL error = syscall.EINVAL
-	errENOENT error = syscall.ENOENT
-)
+	backend Backend
 
-// errnoErr returns common boxed Errno values, to prevent
-// allocations at runtime.
-func errnoErr(e syscall.Errno) error {
-	switch e {
-	case 0:
-		return nil
-	case unix.EAGAIN:
-		return errEAGAIN
-	case unix.EINVAL:
-		return errEINVAL
-	case unix.ENOENT:
-		return errENOENT
-	}
-	return e
+	varMu sync.Mutex
+	vars  *variableTable
+
+	cacheMu sync.Mutex
+	cache   variableCache
+
+	bindMu   sync.Mutex
+	bindings map[reflect.Type]*structBinding
+
+	txMu sync.Mutex
+
+	ioMu      sync.Mutex
+	ioStopped bool
+
+	eventMu sync.Mutex
+	event   *pendingEvent
 }
 
-// ioctl invokes a Unix syscall.
-func ioctl(fd uintptr, req uint, arg uintptr) (r1 uintptr, r2 uintptr, err error) {
-	r1, r2, e1 := unix.Syscall(unix.SYS_IOCTL, fd, uintptr(req), uintptr(arg))
-	if e1 != 0 {
-		err = errnoErr(e1)
-		return r1, r2, err
-	}
-	return r1, r2, nil
+// NewRevPiControl creates a new RevPiControl object using the default piControl device PICONTROL_DEVICE.
+func NewRevPiControl() *RevPiControl {
+	return NewRevPiControlDevice(PICONTROL_DEVICE)
 }
 
-// RevPiControl is an object representing an open file handle to the piControl driver file descriptor.
-type RevPiControl struct {
-	handle *os.File
+// NewRevPiControlDevice creates a new RevPiControl object using the piControl device file at path.
+func NewRevPiControlDevice(path string) *RevPiControl {
+	return NewRevPiControlWithBackend(NewDeviceBackend(path))
 }
 
-// NewRevPiControl creates a new RevPiControl object.
-func NewRevPiControl() *RevPiControl {
-	return &RevPiControl{}
+// NewRevPiControlWithBackend creates a new RevPiControl object delegating to backend.
+func NewRevPiControlWithBackend(backend Backend) *RevPiControl {
+	return &RevPiControl{backend: backend}
 }
 
-// Open opens the file handle.
-// see also: golang.org/x/sys/unix/syscall_unix_test.go
-func (c *RevPiControl) Open() (err error) {
-	/* open handle if needed */
-	if c.handle != nil {
-		return nil
-	}
+// Backend returns the backend the object was created with.
+func (c *RevPiControl) Backend() Backend {
+	return c.backend
+}
 
-	c.handle, err = os.OpenFile(PICONTROL_DEVICE, os.O_RDWR, 0)
-	if err != nil {
-		return err
-	}
-	return nil
+// Open opens the backend, this is done lazily by all other methods if needed.
+func (c *RevPiControl) Open() (err error) {
+	return c.backend.Open()
 }
 
-// Close closes the file handle.
+// Close closes the backend.
 func (c *RevPiControl) Close() (err error) {
-	/* open handle if needed */
-	if c.handle != nil {
-		if err = c.handle.Close(); err != nil {
-			return err
-		}
-		c.handle = nil
-	}
-	return nil
+	return c.backend.Close()
 }
 
 // Reset initializes the Pi Control Interface.
 func (c *RevPiControl) Reset() (err error) {
-	if err = c.Open(); err != nil {
-		return err
-	}
-
-	if _, _, err = ioctl(c.handle.Fd(), KB_RESET, uintptr(0)); err != nil {
+	if err = c.backend.Reset(); err != nil {
 		return err
 	}
+	c.invalidateCaches()
 	return nil
 }
 
 // Read gets process data from a specific position, reads len(pData) bytes from file.
 // Returns number of bytes read or error.
 func (c *RevPiControl) Read(offset uint32, pData []byte) (n int, err error) {
-
-	if err = c.Open(); err != nil {
-		return -1, err
-	}
-	if _, err = c.handle.Seek(int64(offset), 0); err != nil {
-		return -1, err
-	}
-
-	// read
-	return c.handle.Read(pData)
+	return c.backend.Read(offset, pData)
 }
 
 // Write writes process data at a specific position, writes len(pData) bytes to file.
 // Returns number of bytes read or error
 func (c *RevPiControl) Write(offset uint32, pData []byte) (n int, err error) {
-	if err = c.Open(); err != nil {
-		return -1, err
-	}
-	if _, err = c.handle.Seek(int64(offset), 0); err != nil {
-		return -1, err
-	}
-
-	// write
-	return c.handle.Write(pData)
+	return c.backend.Write(offset, pData)
 }
 
 // GetDeviceInfo gets a description of a connected device.
 func (c *RevPiControl) GetDeviceInfo(devInfo *SDeviceInfo) (result int, err error) {
-	if err = c.Open(); err != nil {
-		return 0, err
-	}
-
-	var r uintptr
-	if r, _, err = ioctl(c.handle.Fd(), KB_GET_DEVICE_INFO, uintptr(unsafe.Pointer(devInfo))); err != nil {
-		return 0, err
-	}
-
-	return int(r), nil
+	return c.backend.GetDeviceInfo(devInfo)
 }
 
-// GetDeviceInfoList gets a description of connected devices as an array of 20 elements.
-// Returns the number of detected devices.
+// GetDeviceInfoList gets a description of connected devices.
+// Returns the detected devices.
 func (c *RevPiControl) GetDeviceInfoList() (devInfo []SDeviceInfo, err error) {
-	if err = c.Open(); err != nil {
-		return nil, err
-	}
-	asDevList := make([]SDeviceInfo, 255)
-	var r uintptr
-	if r, _, err = ioctl(c.handle.Fd(), KB_GET_DEVICE_INFO_LIST, uintptr(unsafe.Pointer(&asDevList[0]))); err != nil {
-		return nil, err
-	}
-
-	// cut off the slice
-	devInfo = asDevList[:int(r)]
-
-	return devInfo, nil
+	return c.backend.GetDeviceInfoList()
 }
 
 // GetBitValue gets the value of one bit in the process image.
 func (c *RevPiControl) GetBitValue(pSpiValue *SPIValue) (err error) {
-	if err = c.Open(); err != nil {
-		return err
-	}
-
 	pSpiValue.I16uAddress += uint16(pSpiValue.I8uBit) / 8
 	pSpiValue.I8uBit %= 8
 
-	if _, _, err = ioctl(c.handle.Fd(), KB_GET_VALUE, uintptr(unsafe.Pointer(pSpiValue))); err != nil {
-		return err
-	}
-	return nil
+	return c.backend.GetBitValue(pSpiValue)
 }
 
 // SetBitValue sets the value of one bit in the process image.
 func (c *RevPiControl) SetBitValue(pSpiValue *SPIValue) (err error) {
-	if err = c.Open(); err != nil {
-		return err
-	}
-
 	pSpiValue.I16uAddress += uint16(pSpiValue.I8uBit) / 8
 	pSpiValue.I8uBit %= 8
 
-	if _, _, err = ioctl(c.handle.Fd(), KB_SET_VALUE, uintptr(unsafe.Pointer(pSpiValue))); err != nil {
-		return err
-	}
-	return nil
-}
-
-// GetVariableInfo gets information about a variable by name.
-func (c *RevPiControl) GetVariableInfo(name string) (pSpiVariable *SPIVariable, err error) {
-	if err = c.Open(); err != nil {
-		return nil, err
-	}
-
-	var v SPIVariable
-	v.StrVarName = ByteToUint8Array(([]byte)(name))
-	var r uintptr
-	if r, _, err = ioctl(c.handle.Fd(), KB_FIND_VARIABLE, uintptr(unsafe.Pointer(&v))); err != nil {
-		return nil, err
-	}
-
-	if int(r) < 0 {
-		return nil, fmt.Errorf("could not find variable %s", name)
-	}
-
-	return &v, nil
+	return c.backend.SetBitValue(pSpiValue)
 }
 
 // FindVariable checks if a variable with a specific name exists.
@@ -240,118 +150,94 @@ func (c *RevPiControl) FindVariable(name string) (found bool) {
 
 // ResetCounter resets a counter.
 func (c *RevPiControl) ResetCounter(address uint8, bitfield uint16) (result int, err error) {
+	return c.backend.ResetCounter(address, bitfield)
+}
 
-	if err = c.Open(); err != nil {
-		return -1, err
-	}
-
-	var tel SDIOResetCounter
-	var r uintptr
-
-	tel.I8uAddress = address
-	tel.I16uBitfield = bitfield
-
-	if r, _, err = ioctl(c.handle.Fd(), KB_DIO_RESET_COUNTER, uintptr(unsafe.Pointer(&tel))); err != nil {
-		return int(r), err
+// SetOutputWatchdog activates the output watchdog of the handle: if Write is not called within timeout,
+// the driver sets all outputs to 0. A timeout of 0 deactivates the watchdog.
+// The timeout has a resolution of one millisecond.
+func (c *RevPiControl) SetOutputWatchdog(timeout time.Duration) (err error) {
+	if timeout < 0 || timeout/time.Millisecond > math.MaxUint32 {
+		return fmt.Errorf("invalid output watchdog timeout %v", timeout)
 	}
-
-	if int(r) < 0 {
-		return int(r), fmt.Errorf("could not reset counter")
+	ms := uint32(timeout / time.Millisecond)
+	if timeout > 0 && ms == 0 {
+		ms = 1
 	}
-
-	return int(r), nil
+	return c.backend.SetOutputWatchdog(ms)
 }
 
-// WaitForEvent waits for Reset of Pi Control Interface
-func (c *RevPiControl) WaitForEvent() (err error) {
-	var event int
-
-	if err = c.Open(); err != nil {
-		return err
-	}
+// Modes of StopIO in the Backend interface, as passed to KB_STOP_IO.
+const (
+	IOStart  = 0
+	IOStop   = 1
+	IOToggle = 2
+)
 
-	if ioctl(c.handle.Fd(), KB_WAIT_FOR_EVENT, uintptr(unsafe.Pointer(&event))); err != nil {
-		return err
-	}
-	return nil
+// StopIO stops the I/O communication of the driver with the modules: inputs are no longer updated
+// and outputs no longer sent, while the process image stays readable and writable.
+// This allows to drive the process image from a simulation without the fieldbus overwriting it.
+func (c *RevPiControl) StopIO() error {
+	return c.setIO(IOStop)
 }
 
-// UpdateFirmware update a device firmware, check on the Kunubs website for details about updating firmware.
-func (c *RevPiControl) UpdateFirmware(addrP uint32) (result int, err error) {
+// StartIO restarts the I/O communication stopped by StopIO.
+func (c *RevPiControl) StartIO() error {
+	return c.setIO(IOStart)
+}
 
-	if err = c.Open(); err != nil {
-		return -1, err
+// ToggleIO stops the I/O communication if it is running, restarts it otherwise, and reports whether it is stopped.
+func (c *RevPiControl) ToggleIO() (stopped bool, err error) {
+	if err = c.setIO(IOToggle); err != nil {
+		return false, err
 	}
+	return c.IOStopped(), nil
+}
 
-	var r uintptr
-
-	if addrP == 0 {
-		r, _, err = ioctl(c.handle.Fd(), KB_UPDATE_DEVICE_FIRMWARE, 0)
-	} else {
-		r, _, err = ioctl(c.handle.Fd(), KB_UPDATE_DEVICE_FIRMWARE, uintptr(unsafe.Pointer(&addrP)))
-	}
+// IOStopped reports whether the I/O communication is stopped, as last reported by the driver to this object.
+// The driver has no query for the state, changes made by other processes are not seen before
+// the next StopIO or StartIO call.
+func (c *RevPiControl) IOStopped() bool {
+	c.ioMu.Lock()
+	defer c.ioMu.Unlock()
+	return c.ioStopped
+}
 
+func (c *RevPiControl) setIO(mode int) error {
+	c.ioMu.Lock()
+	defer c.ioMu.Unlock()
+	stopped, err := c.backend.StopIO(mode)
 	if err != nil {
-		return int(r), err
+		return err
 	}
+	c.ioStopped = stopped
+	return nil
+}
 
-	if int(r) < 0 {
-		return int(r), fmt.Errorf("firmware update failed")
-	}
+// WaitForEvent waits for Reset of Pi Control Interface.
+// It cannot be cancelled, see WaitEvent.
+func (c *RevPiControl) WaitForEvent() (err error) {
+	_, err = c.WaitEvent(context.Background())
+	return err
+}
 
-	cMsg := make([]byte, 255)
-	if r, _, err = ioctl(c.handle.Fd(), KB_GET_LAST_MESSAGE, uintptr(unsafe.Pointer(&cMsg[0]))); err != nil && r == 0 && cMsg[0] != 0 {
-		fmt.Println(string(cMsg))
-	}
+// LastMessage returns the last message of the driver, e.g. the result of a firmware update.
+func (c *RevPiControl) LastMessage() (string, error) {
+	return c.backend.LastMessage()
+}
 
-	return int(r), nil
+// UpdateFirmware update a device firmware, check on the Kunubs website for details about updating firmware.
+// On failure the message of the driver is available in the returned *DriverError.
+func (c *RevPiControl) UpdateFirmware(addrP uint32) (result int, err error) {
+	return c.backend.UpdateFirmware(addrP)
 }
 
-// GetModuleName returns a friendly name for a RevPi module type.
+// GetModuleName returns a friendly name for a RevPi module type, see LookupModuleType.
 func GetModuleName(moduletype uint16) string {
-	moduletype = moduletype & PICONTROL_NOT_CONNECTED_MASK
-	switch moduletype {
-	case 95:
-		return "RevPi Core"
-	case 96:
-		return "RevPi DIO"
-	case 97:
-		return "RevPi DI"
-	case 98:
-		return "RevPi DO"
-	case 103:
-		return "RevPi AIO"
-	case PICONTROL_SW_MODBUS_TCP_SLAVE:
-		return "ModbusTCP Slave Adapter"
-	case PICONTROL_SW_MODBUS_RTU_SLAVE:
-		return "ModbusRTU Slave Adapter"
-	case PICONTROL_SW_MODBUS_TCP_MASTER:
-		return "ModbusTCP Master Adapter"
-	case PICONTROL_SW_MODBUS_RTU_MASTER:
-		return "ModbusRTU Master Adapter"
-	case 100:
-		return "Gateway DMX"
-	case 71:
-		return "Gateway CANopen"
-	case 73:
-		return "Gateway DeviceNet"
-	case 74:
-		return "Gateway EtherCAT"
-	case 75:
-		return "Gateway EtherNet/IP"
-	case 93:
-		return "Gateway ModbusTCP"
-	case 76:
-		return "Gateway Powerlink"
-	case 77:
-		return "Gateway Profibus"
-	case 79:
-		return "Gateway Profinet IRT"
-	case 81:
-		return "Gateway SercosIII"
-	default:
-		return "unknown moduletype"
+	if t, ok := LookupModuleType(moduletype); ok {
+		return t.Name
 	}
+	return "unknown moduletype"
 }
 
 // IsModuleConnected checks whether a RevPi module is conneted.
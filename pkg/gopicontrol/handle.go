@@ -0,0 +1,173 @@
+package gopicontrol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Value is the set of Go types a process image variable can be accessed as.
+// bool maps to 1 bit variables, the integer types to variables of the same width.
+type Value interface {
+	bool | uint8 | int8 | uint16 | int16 | uint32 | int32
+}
+
+// Var is a typed handle to a process image variable, the variable is resolved once on creation.
+type Var[T Value] struct {
+	ctrl     *RevPiControl
+	variable SPIVariable
+	name     string
+}
+
+// NewVar resolves the variable name and returns a handle to access it as T.
+// An error is returned if the bit length of the variable does not match T.
+func NewVar[T Value](c *RevPiControl, name string) (*Var[T], error) {
+	v, err := c.GetVariableInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	if bits := valueBits[T](); int(v.I16uLength) != bits {
+		var zero T
+		return nil, fmt.Errorf("variable %s has %d bits and cannot be accessed as %T (%d bits)", name, v.I16uLength, zero, bits)
+	}
+	return &Var[T]{ctrl: c, variable: *v, name: name}, nil
+}
+
+// Bool returns a handle to a 1 bit variable.
+func Bool(c *RevPiControl, name string) (*Var[bool], error) { return NewVar[bool](c, name) }
+
+// Uint8 returns a handle to an unsigned 8 bit variable.
+func Uint8(c *RevPiControl, name string) (*Var[uint8], error) { return NewVar[uint8](c, name) }
+
+// Int8 returns a handle to a signed 8 bit variable.
+func Int8(c *RevPiControl, name string) (*Var[int8], error) { return NewVar[int8](c, name) }
+
+// Uint16 returns a handle to an unsigned 16 bit variable.
+func Uint16(c *RevPiControl, name string) (*Var[uint16], error) { return NewVar[uint16](c, name) }
+
+// Int16 returns a handle to a signed 16 bit variable.
+func Int16(c *RevPiControl, name string) (*Var[int16], error) { return NewVar[int16](c, name) }
+
+// Uint32 returns a handle to an unsigned 32 bit variable.
+func Uint32(c *RevPiControl, name string) (*Var[uint32], error) { return NewVar[uint32](c, name) }
+
+// Int32 returns a handle to a signed 32 bit variable.
+func Int32(c *RevPiControl, name string) (*Var[int32], error) { return NewVar[int32](c, name) }
+
+// Name returns the variable name.
+func (h *Var[T]) Name() string {
+	return h.name
+}
+
+// Info returns the variable information resolved on creation.
+func (h *Var[T]) Info() SPIVariable {
+	return h.variable
+}
+
+// Get reads the variable from the process image.
+func (h *Var[T]) Get() (v T, err error) {
+	if h.variable.I16uLength == 1 {
+		val := SPIValue{I16uAddress: h.variable.I16uAddress, I8uBit: h.variable.I8uBit}
+		if err = h.ctrl.GetBitValue(&val); err != nil {
+			return v, err
+		}
+		return decodeValue[T](uint32(val.I8uValue)), nil
+	}
+
+	data := make([]byte, h.variable.I16uLength/8)
+	n, err := h.ctrl.Read(uint32(h.variable.I16uAddress), data)
+	if err != nil {
+		return v, err
+	}
+	if n != len(data) {
+		return v, fmt.Errorf("could not read variable %s: %d of %d bytes read", h.name, n, len(data))
+	}
+	return decodeValue[T](leUint32(data)), nil
+}
+
+// Set writes the variable to the process image.
+func (h *Var[T]) Set(v T) (err error) {
+	raw := encodeValue(v)
+	if h.variable.I16uLength == 1 {
+		val := SPIValue{I16uAddress: h.variable.I16uAddress, I8uBit: h.variable.I8uBit, I8uValue: uint8(raw)}
+		return h.ctrl.SetBitValue(&val)
+	}
+
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, raw)
+	data = data[:h.variable.I16uLength/8]
+	n, err := h.ctrl.Write(uint32(h.variable.I16uAddress), data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("could not write variable %s: %d of %d bytes written", h.name, n, len(data))
+	}
+	return nil
+}
+
+// valueBits returns the bit length of the variables which can be accessed as T.
+func valueBits[T Value]() int {
+	var zero T
+	switch any(zero).(type) {
+	case bool:
+		return 1
+	case uint8, int8:
+		return 8
+	case uint16, int16:
+		return 16
+	default:
+		return 32
+	}
+}
+
+// decodeValue converts the raw little endian value of a variable to T, sign extending signed types.
+func decodeValue[T Value](raw uint32) (v T) {
+	switch p := any(&v).(type) {
+	case *bool:
+		*p = raw != 0
+	case *uint8:
+		*p = uint8(raw)
+	case *int8:
+		*p = int8(raw)
+	case *uint16:
+		*p = uint16(raw)
+	case *int16:
+		*p = int16(raw)
+	case *uint32:
+		*p = raw
+	case *int32:
+		*p = int32(raw)
+	}
+	return v
+}
+
+// encodeValue converts v to the raw value of a variable of the same width.
+func encodeValue[T Value](v T) uint32 {
+	switch x := any(v).(type) {
+	case bool:
+		if x {
+			return 1
+		}
+	case uint8:
+		return uint32(x)
+	case int8:
+		return uint32(uint8(x))
+	case uint16:
+		return uint32(x)
+	case int16:
+		return uint32(uint16(x))
+	case uint32:
+		return x
+	case int32:
+		return uint32(x)
+	}
+	return 0
+}
+
+// leUint32 decodes up to 4 little endian bytes.
+func leUint32(b []byte) (v uint32) {
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint32(b[i])
+	}
+	return v
+}
@@ -0,0 +1,87 @@
+package gopicontrol_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestVarLengthMismatch(t *testing.T) {
+	c, _ := newSimControl(t)
+	if _, err := gopicontrol.Uint8(c, "Value"); err == nil {
+		t.Error("accessing the 16 bit Value as uint8 succeeded")
+	}
+	if _, err := gopicontrol.Int32(c, "Value"); err == nil {
+		t.Error("accessing the 16 bit Value as int32 succeeded")
+	}
+	if _, err := gopicontrol.Bool(c, "PWM_1"); err == nil {
+		t.Error("accessing the 8 bit PWM_1 as bool succeeded")
+	}
+	if _, err := gopicontrol.Uint16(c, "O_1"); err == nil {
+		t.Error("accessing the bit O_1 as uint16 succeeded")
+	}
+	if _, err := gopicontrol.Bool(c, "Missing"); !errors.Is(err, gopicontrol.ErrVariableNotFound) {
+		t.Errorf("got %v, want ErrVariableNotFound", err)
+	}
+}
+
+func TestVarSigned(t *testing.T) {
+	c, b := newSimControl(t)
+	setValue(t, b, "InputStatus", 0xff38)
+
+	signed, err := gopicontrol.Int16(c, "InputStatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := signed.Get(); err != nil || v != -200 {
+		t.Errorf("got %d, %v, want -200", v, err)
+	}
+	unsigned, err := gopicontrol.Uint16(c, "InputStatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := unsigned.Get(); err != nil || v != 0xff38 {
+		t.Errorf("got %d, %v, want %d", v, err, 0xff38)
+	}
+
+	out, err := gopicontrol.Int16(c, "Value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = out.Set(-1); err != nil {
+		t.Fatal(err)
+	}
+	if got := value(t, b, "Value"); got != 0xffff {
+		t.Errorf("got Value 0x%x, want 0xffff", got)
+	}
+	if got := value(t, b, "Spare"); got != 0 {
+		t.Errorf("writing Value changed Spare to 0x%x", got)
+	}
+}
+
+func TestVarSetBit(t *testing.T) {
+	c, b := newSimControl(t)
+	setValue(t, b, "O_3", 1)
+	b.counts()
+
+	o2, err := gopicontrol.Bool(c, "O_2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = o2.Set(true); err != nil {
+		t.Fatal(err)
+	}
+	if on, err := o2.Get(); err != nil || !on {
+		t.Errorf("got O_2 %t, %v, want true", on, err)
+	}
+	if _, writes, setBit := b.counts(); writes != 0 || setBit != 1 {
+		t.Errorf("got %d writes and %d bit sets, want 0 and 1", writes, setBit)
+	}
+	// O_1 has the default 1
+	for name, want := range map[string]uint32{"O_1": 1, "O_2": 1, "O_3": 1, "PWM_1": 0} {
+		if got := value(t, b, name); got != want {
+			t.Errorf("got %s %d, want %d", name, got, want)
+		}
+	}
+}
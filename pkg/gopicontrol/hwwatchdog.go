@@ -0,0 +1,162 @@
+package gopicontrol
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HWWatchdogTimeout is the timeout of the hardware watchdog of the RevPi Connect:
+// the system is reset if the PICONTROL_WD_TRIGGER bit of RevPiLED does not toggle within it.
+const HWWatchdogTimeout = 60 * time.Second
+
+// DefaultWatchdogPeriod is the default toggle period of a Watchdog, well below HWWatchdogTimeout.
+const DefaultWatchdogPeriod = 10 * time.Second
+
+// HealthCheck is a check of the application health, it returns an error if the application is not healthy.
+type HealthCheck func() error
+
+// Watchdog keeps the hardware watchdog of the RevPi Connect triggered by toggling the PICONTROL_WD_TRIGGER
+// bit of RevPiLED, as long as all registered health checks pass. When a check fails, or the goroutine running
+// the watchdog hangs, the bit stops toggling and the hardware resets the system after HWWatchdogTimeout.
+// Watchdog is safe for concurrent use.
+type Watchdog struct {
+	ctrl   *RevPiControl
+	period time.Duration
+
+	toggleMu sync.Mutex // serializes toggles
+	mu       sync.Mutex
+	checks   map[string]HealthCheck
+	err      error
+}
+
+// NewWatchdog creates a watchdog toggling the trigger bit every period, DefaultWatchdogPeriod if 0.
+func NewWatchdog(c *RevPiControl, period time.Duration) *Watchdog {
+	if period <= 0 {
+		period = DefaultWatchdogPeriod
+	}
+	return &Watchdog{ctrl: c, period: period, checks: map[string]HealthCheck{}}
+}
+
+// AddCheck registers a health check by name, replacing a check with the same name.
+func (w *Watchdog) AddCheck(name string, check HealthCheck) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.checks[name] = check
+}
+
+// RemoveCheck unregisters the health check name.
+func (w *Watchdog) RemoveCheck(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.checks, name)
+}
+
+// Heartbeat registers a liveness check named name for a goroutine: the goroutine must call Beat
+// on the returned heartbeat at least every maxAge, otherwise the check fails.
+func (w *Watchdog) Heartbeat(name string, maxAge time.Duration) *Heartbeat {
+	h := &Heartbeat{name: name, maxAge: maxAge, last: time.Now()}
+	w.AddCheck(name, h.check)
+	return h
+}
+
+// Heartbeat is the liveness signal of a goroutine, see Watchdog.Heartbeat.
+type Heartbeat struct {
+	name   string
+	maxAge time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Beat signals that the goroutine is alive.
+func (h *Heartbeat) Beat() {
+	h.mu.Lock()
+	h.last = time.Now()
+	h.mu.Unlock()
+}
+
+func (h *Heartbeat) check() error {
+	h.mu.Lock()
+	age := time.Since(h.last)
+	h.mu.Unlock()
+	if age > h.maxAge {
+		return fmt.Errorf("no heartbeat for %v", age.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// Check runs all health checks and returns the first error, in the order of the check names.
+func (w *Watchdog) Check() error {
+	w.mu.Lock()
+	names := make([]string, 0, len(w.checks))
+	for name := range w.checks {
+		names = append(names, name)
+	}
+	checks := make([]HealthCheck, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		checks[i] = w.checks[name]
+	}
+	w.mu.Unlock()
+
+	for i, check := range checks {
+		if err := check(); err != nil {
+			return fmt.Errorf("health check %s failed: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
+// Trigger runs the health checks and, if they pass, toggles the trigger bit once.
+func (w *Watchdog) Trigger() error {
+	err := w.Check()
+	if err == nil {
+		err = w.toggle()
+	}
+	w.mu.Lock()
+	w.err = err
+	w.mu.Unlock()
+	return err
+}
+
+// Err returns the error of the last Trigger, nil if the bit was toggled.
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Run calls Trigger every period until ctx is done.
+// Failed checks do not stop the watchdog, the bit toggles again as soon as the checks pass.
+func (w *Watchdog) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.period)
+	defer ticker.Stop()
+	for {
+		w.Trigger()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// toggle inverts the trigger bit with a KB_GET_VALUE/KB_SET_VALUE pair, leaving the other bits of RevPiLED unchanged.
+// Starting from the current value of the bit, every toggle produces an edge.
+func (w *Watchdog) toggle() error {
+	val, err := ledBit(w.ctrl, PICONTROL_WD_TRIGGER)
+	if err != nil {
+		return err
+	}
+
+	w.toggleMu.Lock()
+	defer w.toggleMu.Unlock()
+	if err = w.ctrl.GetBitValue(&val); err != nil {
+		return err
+	}
+	val.I8uValue ^= 1
+	return w.ctrl.SetBitValue(&val)
+}
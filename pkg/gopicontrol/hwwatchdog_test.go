@@ -0,0 +1,38 @@
+package gopicontrol
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWatchdogToggleStartsFromCurrentBit(t *testing.T) {
+	for _, initial := range []byte{0x00, PICONTROL_WD_TRIGGER | PICONTROL_LED_A1_GREEN} {
+		fake := newFakeBackend()
+		fake.image[20] = initial
+		w := NewWatchdog(NewRevPiControlWithBackend(fake), 0)
+
+		for i := 0; i < 3; i++ {
+			before := fake.image[20]
+			if err := w.Trigger(); err != nil {
+				t.Fatal(err)
+			}
+			after := fake.image[20]
+			if (before ^ after) != PICONTROL_WD_TRIGGER {
+				t.Errorf("initial 0x%02x, trigger %d: RevPiLED 0x%02x -> 0x%02x, want only the trigger bit toggled", initial, i, before, after)
+			}
+		}
+	}
+}
+
+func TestWatchdogFailedCheck(t *testing.T) {
+	fake := newFakeBackend()
+	w := NewWatchdog(NewRevPiControlWithBackend(fake), 0)
+	errDown := errors.New("down")
+	w.AddCheck("service", func() error { return errDown })
+	if err := w.Trigger(); !errors.Is(err, errDown) {
+		t.Fatalf("got %v, want the check error", err)
+	}
+	if fake.image[20] != 0 {
+		t.Errorf("RevPiLED changed to 0x%02x with a failed check", fake.image[20])
+	}
+}
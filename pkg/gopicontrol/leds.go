@@ -0,0 +1,259 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LEDVariable is the output variable of the RevPi Core driving the front LEDs,
+// the X2 digital output and the hardware watchdog of the RevPi Connect.
+const LEDVariable = "RevPiLED"
+
+// LED is a front LED of the RevPi Core, Compact or Connect.
+type LED int
+
+// LEDs, A3 is available only on the RevPi Connect and Compact.
+const (
+	LEDA1 LED = iota
+	LEDA2
+	LEDA3
+)
+
+// ledBits are the green and red bits of each LED in RevPiLED.
+var ledBits = [...]struct{ green, red uint8 }{
+	LEDA1: {PICONTROL_LED_A1_GREEN, PICONTROL_LED_A1_RED},
+	LEDA2: {PICONTROL_LED_A2_GREEN, PICONTROL_LED_A2_RED},
+	LEDA3: {PICONTROL_LED_A3_GREEN, PICONTROL_LED_A3_RED},
+}
+
+func (l LED) String() string {
+	return fmt.Sprintf("A%d", int(l)+1)
+}
+
+// ParseLED parses an LED name, A1, A2 or A3.
+func ParseLED(s string) (LED, error) {
+	for l := range ledBits {
+		if strings.EqualFold(s, LED(l).String()) {
+			return LED(l), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid LED %q, expected A1, A2 or A3", s)
+}
+
+// LEDColor is the color of a front LED.
+type LEDColor int
+
+// LED colors, orange lights green and red together.
+const (
+	LEDOff LEDColor = iota
+	LEDGreen
+	LEDRed
+	LEDOrange
+)
+
+var ledColorNames = [...]string{LEDOff: "off", LEDGreen: "green", LEDRed: "red", LEDOrange: "orange"}
+
+func (c LEDColor) String() string {
+	if c < 0 || int(c) >= len(ledColorNames) {
+		return fmt.Sprintf("color %d", int(c))
+	}
+	return ledColorNames[c]
+}
+
+// ParseLEDColor parses a color name: off, green, red or orange.
+func ParseLEDColor(s string) (LEDColor, error) {
+	for c, name := range ledColorNames {
+		if strings.EqualFold(s, name) {
+			return LEDColor(c), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid LED color %q, expected off, green, red or orange", s)
+}
+
+// LEDs controls the front LEDs and the X2 digital output of the RevPi Core through RevPiLED.
+// Each bit is set with KB_SET_VALUE, which the driver performs atomically, so that LEDs, X2 output and
+// the hardware watchdog trigger can be driven independently, also by other programs.
+// LEDs is safe for concurrent use.
+type LEDs struct {
+	ctrl *RevPiControl
+
+	opMu   sync.Mutex // serializes Set, Blink and Close
+	mu     sync.Mutex
+	blinks map[LED]*blinker
+}
+
+// blinker is the goroutine blinking an LED.
+type blinker struct {
+	stop, done chan struct{}
+}
+
+// NewLEDs returns the LED controller of c.
+func NewLEDs(c *RevPiControl) *LEDs {
+	return &LEDs{ctrl: c, blinks: map[LED]*blinker{}}
+}
+
+// Set sets the color of led, stopping it from blinking.
+func (l *LEDs) Set(led LED, color LEDColor) error {
+	if err := checkLED(led, color); err != nil {
+		return err
+	}
+	l.opMu.Lock()
+	defer l.opMu.Unlock()
+	l.stopBlink(led)
+	return l.write(led, color)
+}
+
+// Get returns the color of led.
+func (l *LEDs) Get(led LED) (LEDColor, error) {
+	if err := checkLED(led, LEDOff); err != nil {
+		return LEDOff, err
+	}
+	v, err := l.value()
+	if err != nil {
+		return LEDOff, err
+	}
+	var color LEDColor
+	if v&ledBits[led].green != 0 {
+		color |= LEDGreen
+	}
+	if v&ledBits[led].red != 0 {
+		color |= LEDRed
+	}
+	return color, nil
+}
+
+// Blink switches led between color and off every interval in a background goroutine,
+// until Set, Blink or Close is called.
+func (l *LEDs) Blink(led LED, color LEDColor, interval time.Duration) error {
+	if err := checkLED(led, color); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid blink interval %v", interval)
+	}
+	l.opMu.Lock()
+	defer l.opMu.Unlock()
+	l.stopBlink(led)
+	if err := l.write(led, color); err != nil {
+		return err
+	}
+
+	b := &blinker{stop: make(chan struct{}), done: make(chan struct{})}
+	l.mu.Lock()
+	l.blinks[led] = b
+	l.mu.Unlock()
+
+	go func() {
+		defer close(b.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		on := true
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+			}
+			on = !on
+			c := LEDOff
+			if on {
+				c = color
+			}
+			// a failed write is retried at the next interval
+			l.write(led, c)
+		}
+	}()
+	return nil
+}
+
+// SetX2Out sets the X2 digital output of the RevPi Connect.
+func (l *LEDs) SetX2Out(on bool) error {
+	return setLEDBit(l.ctrl, PICONTROL_X2_DOUT, on)
+}
+
+// X2Out returns the state of the X2 digital output of the RevPi Connect.
+func (l *LEDs) X2Out() (bool, error) {
+	v, err := l.value()
+	return v&PICONTROL_X2_DOUT != 0, err
+}
+
+// Close stops all blinking LEDs, leaving them in their current state.
+func (l *LEDs) Close() error {
+	l.opMu.Lock()
+	defer l.opMu.Unlock()
+	for led := range ledBits {
+		l.stopBlink(LED(led))
+	}
+	return nil
+}
+
+// stopBlink stops the blinking of led and waits for its goroutine to exit.
+func (l *LEDs) stopBlink(led LED) {
+	l.mu.Lock()
+	b, ok := l.blinks[led]
+	delete(l.blinks, led)
+	l.mu.Unlock()
+	if ok {
+		close(b.stop)
+		<-b.done
+	}
+}
+
+// write sets the green and then the red bit of led.
+func (l *LEDs) write(led LED, color LEDColor) error {
+	if err := setLEDBit(l.ctrl, ledBits[led].green, color&LEDGreen != 0); err != nil {
+		return err
+	}
+	return setLEDBit(l.ctrl, ledBits[led].red, color&LEDRed != 0)
+}
+
+// value reads the RevPiLED byte.
+func (l *LEDs) value() (uint8, error) {
+	v, err := l.ctrl.GetVariableInfo(LEDVariable)
+	if err != nil {
+		return 0, err
+	}
+	var b [1]byte
+	if _, err = l.ctrl.Read(uint32(v.I16uAddress), b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// ledBit returns the address of the RevPiLED bit of c selected by mask.
+func ledBit(c *RevPiControl, mask uint8) (SPIValue, error) {
+	v, err := c.GetVariableInfo(LEDVariable)
+	if err != nil {
+		return SPIValue{}, err
+	}
+	for bit := uint8(0); bit < 8; bit++ {
+		if mask == 1<<bit {
+			return SPIValue{I16uAddress: v.I16uAddress, I8uBit: v.I8uBit + bit}, nil
+		}
+	}
+	return SPIValue{}, fmt.Errorf("invalid RevPiLED mask 0x%x", mask)
+}
+
+// setLEDBit sets the RevPiLED bit of c selected by mask.
+func setLEDBit(c *RevPiControl, mask uint8, on bool) error {
+	val, err := ledBit(c, mask)
+	if err != nil {
+		return err
+	}
+	if on {
+		val.I8uValue = 1
+	}
+	return c.SetBitValue(&val)
+}
+
+func checkLED(led LED, color LEDColor) error {
+	if led < 0 || int(led) >= len(ledBits) {
+		return fmt.Errorf("invalid LED %d", int(led))
+	}
+	if color < LEDOff || color > LEDOrange {
+		return fmt.Errorf("invalid LED color %d", int(color))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package gopicontrol_test
+
+import (
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestLEDsSetBits(t *testing.T) {
+	c, b := newSimControl(t)
+	setValue(t, b, gopicontrol.LEDVariable, gopicontrol.PICONTROL_LED_A2_GREEN|gopicontrol.PICONTROL_WD_TRIGGER)
+	b.counts()
+
+	leds := gopicontrol.NewLEDs(c)
+	defer leds.Close()
+	if err := leds.Set(gopicontrol.LEDA1, gopicontrol.LEDRed); err != nil {
+		t.Fatal(err)
+	}
+	if err := leds.SetX2Out(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, writes, setBit := b.counts(); writes != 0 || setBit != 3 {
+		t.Errorf("got %d writes and %d bit sets, want 0 and 3", writes, setBit)
+	}
+
+	want := uint32(gopicontrol.PICONTROL_LED_A1_RED | gopicontrol.PICONTROL_LED_A2_GREEN | gopicontrol.PICONTROL_X2_DOUT | gopicontrol.PICONTROL_WD_TRIGGER)
+	if got := value(t, b, gopicontrol.LEDVariable); got != want {
+		t.Errorf("got RevPiLED 0x%02x, want 0x%02x", got, want)
+	}
+	if color, err := leds.Get(gopicontrol.LEDA2); err != nil || color != gopicontrol.LEDGreen {
+		t.Errorf("got A2 %v, %v, want green", color, err)
+	}
+}
@@ -0,0 +1,199 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ModuleFamily is the kind of a RevPi module, derived from the ranges of module types in piControl.h.
+type ModuleFamily int
+
+// Module families.
+const (
+	FamilyUnknown ModuleFamily = iota
+	FamilyCore
+	FamilyIO
+	FamilyGateway
+	FamilySoftwareAdapter // virtual devices, from PICONTROL_SW_OFFSET below userModuleTypeFirst
+	FamilyUserDefined     // user defined adapters, from userModuleTypeFirst
+)
+
+func (f ModuleFamily) String() string {
+	switch f {
+	case FamilyCore:
+		return "core"
+	case FamilyIO:
+		return "IO"
+	case FamilyGateway:
+		return "gateway"
+	case FamilySoftwareAdapter:
+		return "software adapter"
+	case FamilyUserDefined:
+		return "user-defined"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the family as its name in JSON.
+func (f ModuleFamily) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// Capability is a set of features of a module type.
+type Capability uint32
+
+// Module capabilities.
+const (
+	CapDigitalInputs  Capability = 1 << iota
+	CapDigitalOutputs            // digital outputs, with output fault detection
+	CapCounters                  // counters and encoders on the digital inputs, see ResetCounter
+	CapPWM                       // PWM on the digital outputs
+	CapAnalogInputs
+	CapAnalogOutputs
+	CapRTD           // RTD temperature inputs
+	CapLEDs          // front LEDs controlled by RevPiLED
+	CapX2            // X2 digital input and output on the base module
+	CapHWWatchdog    // hardware watchdog triggered by RevPiLED
+	CapGatewayConfig // configuration downloaded with DownloadGatewayConfig
+)
+
+// ModuleType describes a module type known to the piControl driver.
+type ModuleType struct {
+	ID     uint16
+	Name   string
+	Family ModuleFamily
+	// InputLength and OutputLength are the lengths in bytes of the inputs and outputs of a module
+	// of this type in the process image, 0 if they depend on the configuration.
+	InputLength, OutputLength uint16
+	Capabilities              Capability
+}
+
+// Has reports whether the module type has all the capabilities in c.
+func (t ModuleType) Has(c Capability) bool {
+	return t.Capabilities&c == c
+}
+
+// Module types of the DIO, DI and DO, see DIOCounterOffset for their counters.
+const (
+	ModuleTypeDIO uint16 = 96
+	ModuleTypeDI  uint16 = 97
+	ModuleTypeDO  uint16 = 98
+)
+
+// builtinModuleTypes are the module types known to piTest.
+// DIO, DI and DO share the process image layout of the DIO.
+var builtinModuleTypes = []ModuleType{
+	{ID: 95, Name: "RevPi Core", Family: FamilyCore, Capabilities: CapLEDs},
+	{ID: 104, Name: "RevPi Compact", Family: FamilyCore, Capabilities: CapLEDs | CapDigitalInputs | CapDigitalOutputs | CapAnalogInputs | CapAnalogOutputs},
+	{ID: 105, Name: "RevPi Connect", Family: FamilyCore, Capabilities: CapLEDs | CapX2 | CapHWWatchdog},
+
+	{ID: ModuleTypeDIO, Name: "RevPi DIO", Family: FamilyIO, InputLength: 70, OutputLength: 18, Capabilities: CapDigitalInputs | CapDigitalOutputs | CapCounters | CapPWM},
+	{ID: ModuleTypeDI, Name: "RevPi DI", Family: FamilyIO, InputLength: 70, OutputLength: 18, Capabilities: CapDigitalInputs | CapCounters},
+	{ID: ModuleTypeDO, Name: "RevPi DO", Family: FamilyIO, InputLength: 70, OutputLength: 18, Capabilities: CapDigitalOutputs | CapPWM},
+	{ID: 103, Name: "RevPi AIO", Family: FamilyIO, Capabilities: CapAnalogInputs | CapAnalogOutputs | CapRTD},
+	{ID: 109, Name: "RevPi CON CAN", Family: FamilyIO},
+	{ID: 110, Name: "RevPi CON M-Bus", Family: FamilyIO},
+	{ID: 111, Name: "RevPi CON BT", Family: FamilyIO},
+
+	{ID: 71, Name: "Gateway CANopen", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 72, Name: "Gateway CC-Link", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 73, Name: "Gateway DeviceNet", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 74, Name: "Gateway EtherCAT", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 75, Name: "Gateway EtherNet/IP", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 76, Name: "Gateway Powerlink", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 77, Name: "Gateway Profibus", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 78, Name: "Gateway Profinet RT", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 79, Name: "Gateway Profinet IRT", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 80, Name: "Gateway CANopen Master", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 81, Name: "Gateway SercosIII", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 82, Name: "Gateway Serial", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 85, Name: "Gateway EtherCAT Master", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 92, Name: "Gateway ModbusRTU", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 93, Name: "Gateway ModbusTCP", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+	{ID: 100, Name: "Gateway DMX", Family: FamilyGateway, InputLength: 512, OutputLength: 512, Capabilities: CapGatewayConfig},
+
+	{ID: PICONTROL_SW_MODBUS_TCP_SLAVE, Name: "ModbusTCP Slave Adapter", Family: FamilySoftwareAdapter},
+	{ID: PICONTROL_SW_MODBUS_RTU_SLAVE, Name: "ModbusRTU Slave Adapter", Family: FamilySoftwareAdapter},
+	{ID: PICONTROL_SW_MODBUS_TCP_MASTER, Name: "ModbusTCP Master Adapter", Family: FamilySoftwareAdapter},
+	{ID: PICONTROL_SW_MODBUS_RTU_MASTER, Name: "ModbusRTU Master Adapter", Family: FamilySoftwareAdapter},
+	{ID: PICONTROL_SW_PROFINET_CONTROLLER, Name: "Profinet Controller Adapter", Family: FamilySoftwareAdapter},
+	{ID: PICONTROL_SW_PROFINET_DEVICE, Name: "Profinet Device Adapter", Family: FamilySoftwareAdapter},
+	{ID: PICONTROL_SW_REVPI_SEVEN, Name: "RevPi7 Adapter", Family: FamilySoftwareAdapter},
+	{ID: PICONTROL_SW_REVPI_CLOUD, Name: "RevPi Cloud Adapter", Family: FamilySoftwareAdapter},
+}
+
+// moduleTypes is the registry of module types, indexed by ID.
+var moduleTypes = struct {
+	sync.RWMutex
+	byID map[uint16]ModuleType
+}{byID: map[uint16]ModuleType{}}
+
+// userModuleTypeFirst is the first ID of the user-defined adapter types.
+// PICONTROL_USER_MODULE_TYPE of piControl.h is an old definition overlapping PICONTROL_NOT_CONNECTED.
+const userModuleTypeFirst = 0x7001
+
+func init() {
+	for _, t := range builtinModuleTypes {
+		moduleTypes.byID[t.ID] = t
+	}
+}
+
+// FamilyOf returns the family of a module type from the ID ranges of piControl.h,
+// types below PICONTROL_SW_OFFSET are classified only if they are registered.
+func FamilyOf(moduletype uint16) ModuleFamily {
+	moduletype &= PICONTROL_NOT_CONNECTED_MASK
+	switch {
+	case moduletype >= userModuleTypeFirst:
+		return FamilyUserDefined
+	case moduletype >= PICONTROL_SW_OFFSET:
+		return FamilySoftwareAdapter
+	}
+	if t, ok := LookupModuleType(moduletype); ok {
+		return t.Family
+	}
+	return FamilyUnknown
+}
+
+// LookupModuleType returns the registered description of a module type.
+// The PICONTROL_NOT_CONNECTED flag of moduletype is ignored.
+func LookupModuleType(moduletype uint16) (ModuleType, bool) {
+	moduleTypes.RLock()
+	defer moduleTypes.RUnlock()
+	t, ok := moduleTypes.byID[moduletype&PICONTROL_NOT_CONNECTED_MASK]
+	return t, ok
+}
+
+// ModuleTypes returns all registered module types sorted by ID.
+func ModuleTypes() []ModuleType {
+	moduleTypes.RLock()
+	types := make([]ModuleType, 0, len(moduleTypes.byID))
+	for _, t := range moduleTypes.byID {
+		types = append(types, t)
+	}
+	moduleTypes.RUnlock()
+	sort.Slice(types, func(i, j int) bool { return types[i].ID < types[j].ID })
+	return types
+}
+
+// RegisterModuleType registers a user-defined adapter type, with an ID from userModuleTypeFirst
+// (0x7001-0x7fff, the highest bit of the type is the PICONTROL_NOT_CONNECTED flag).
+// The family of t is set to FamilyUserDefined, an ID can be registered only once.
+func RegisterModuleType(t ModuleType) error {
+	if t.ID < userModuleTypeFirst || t.ID > PICONTROL_NOT_CONNECTED_MASK {
+		return fmt.Errorf("module type 0x%x is not in the user-defined range 0x%x-0x%x", t.ID, userModuleTypeFirst, PICONTROL_NOT_CONNECTED_MASK)
+	}
+	if t.Name == "" {
+		return fmt.Errorf("module type 0x%x has no name", t.ID)
+	}
+	t.Family = FamilyUserDefined
+
+	moduleTypes.Lock()
+	defer moduleTypes.Unlock()
+	if old, ok := moduleTypes.byID[t.ID]; ok {
+		return fmt.Errorf("module type 0x%x is already registered as %s", t.ID, old.Name)
+	}
+	moduleTypes.byID[t.ID] = t
+	return nil
+}
@@ -0,0 +1,80 @@
+package gopicontrol_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestRegisterModuleTypeRange(t *testing.T) {
+	for _, id := range []uint16{
+		0,
+		95, // RevPi Core
+		gopicontrol.ModuleTypeDIO,
+		gopicontrol.PICONTROL_SW_MODBUS_TCP_SLAVE, // built-in software adapter
+		gopicontrol.PICONTROL_SW_REVPI_CLOUD,      // built-in software adapter
+		0x7000,
+		0x8000, // PICONTROL_NOT_CONNECTED
+		0xffff,
+	} {
+		if err := gopicontrol.RegisterModuleType(gopicontrol.ModuleType{ID: id, Name: "test"}); err == nil {
+			t.Errorf("registering module type 0x%x succeeded", id)
+		}
+	}
+	if core, _ := gopicontrol.LookupModuleType(95); core.Name != "RevPi Core" {
+		t.Errorf("got module type 95 %q, want the RevPi Core", core.Name)
+	}
+	if err := gopicontrol.RegisterModuleType(gopicontrol.ModuleType{ID: 0x7002}); err == nil {
+		t.Error("registering a module type without name succeeded")
+	}
+}
+
+func TestRegisterModuleType(t *testing.T) {
+	for _, id := range []uint16{0x7001, 0x7fff} {
+		// the registry is global, the types stay registered when the test is repeated
+		if _, ok := gopicontrol.LookupModuleType(id); !ok {
+			err := gopicontrol.RegisterModuleType(gopicontrol.ModuleType{ID: id, Name: "Sensor adapter", Family: gopicontrol.FamilyIO})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := gopicontrol.RegisterModuleType(gopicontrol.ModuleType{ID: id, Name: "Other adapter"}); err == nil {
+			t.Errorf("registering module type 0x%x twice succeeded", id)
+		}
+
+		mt, ok := gopicontrol.LookupModuleType(id | gopicontrol.PICONTROL_NOT_CONNECTED)
+		if !ok || mt.Name != "Sensor adapter" || mt.Family != gopicontrol.FamilyUserDefined {
+			t.Errorf("got module type 0x%x %+v, %t, want the user-defined Sensor adapter", id, mt, ok)
+		}
+		if f := gopicontrol.FamilyOf(id); f != gopicontrol.FamilyUserDefined {
+			t.Errorf("got family %v of 0x%x, want user-defined", f, id)
+		}
+	}
+}
+
+func TestModuleFamilies(t *testing.T) {
+	for _, tc := range []struct {
+		id     uint16
+		family gopicontrol.ModuleFamily
+	}{
+		{95, gopicontrol.FamilyCore},
+		{gopicontrol.ModuleTypeDI | gopicontrol.PICONTROL_NOT_CONNECTED, gopicontrol.FamilyIO},
+		{71, gopicontrol.FamilyGateway},
+		{0x6100, gopicontrol.FamilySoftwareAdapter},
+		{0x7100, gopicontrol.FamilyUserDefined},
+		{1, gopicontrol.FamilyUnknown},
+	} {
+		if f := gopicontrol.FamilyOf(tc.id); f != tc.family {
+			t.Errorf("got family %v of 0x%x, want %v", f, tc.id, tc.family)
+		}
+	}
+
+	b, err := json.Marshal(map[string]gopicontrol.ModuleFamily{"family": gopicontrol.FamilySoftwareAdapter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"family":"software adapter"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
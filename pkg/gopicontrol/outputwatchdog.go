@@ -0,0 +1,53 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"time"
+)
+
+// OutputWatchdog keeps the output watchdog of the driver fed from an application loop.
+// The loop calls Feed once per cycle: if the loop hangs the feeding stops and after the timeout
+// the driver sets all outputs to 0, bringing the machine to a safe state.
+// Every Write on the RevPiControl feeds the watchdog as well, Feed is only needed in cycles without writes.
+type OutputWatchdog struct {
+	ctrl    *RevPiControl
+	timeout time.Duration
+	feed    Region
+}
+
+// NewOutputWatchdog creates an output watchdog with the given timeout.
+// Feed rewrites the region feed with the value it reads just before, so the region must be owned by
+// the application and not shared: a write to it between the read and the rewrite is reverted.
+// Only the transactions of the same RevPiControl are serialized with Feed; SetBitValue, Var.Set, WriteFrom
+// and other processes are not. RevPiLED, which holds the LED and hardware watchdog bits, is not a suitable region.
+func NewOutputWatchdog(c *RevPiControl, timeout time.Duration, feed Region) *OutputWatchdog {
+	return &OutputWatchdog{ctrl: c, timeout: timeout, feed: feed}
+}
+
+// Start activates the watchdog in the driver.
+func (w *OutputWatchdog) Start() error {
+	return w.ctrl.SetOutputWatchdog(w.timeout)
+}
+
+// Stop deactivates the watchdog in the driver.
+func (w *OutputWatchdog) Stop() error {
+	return w.ctrl.SetOutputWatchdog(0)
+}
+
+// Feed restarts the watchdog timeout by rewriting the feed region, see NewOutputWatchdog.
+func (w *OutputWatchdog) Feed() error {
+	if w.feed.Length == 0 || w.feed.Offset+w.feed.Length > ProcessImageLength {
+		return fmt.Errorf("invalid feed region at offset %d with length %d", w.feed.Offset, w.feed.Length)
+	}
+
+	// serialize with the commits of transactions
+	w.ctrl.txMu.Lock()
+	defer w.ctrl.txMu.Unlock()
+
+	data := make([]byte, w.feed.Length)
+	if _, err := w.ctrl.Read(w.feed.Offset, data); err != nil {
+		return err
+	}
+	_, err := w.ctrl.Write(w.feed.Offset, data)
+	return err
+}
@@ -0,0 +1,194 @@
+package gopicontrol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Edge selects the changes delivered to a subscription.
+type Edge int
+
+// Edge filters, EdgeRising and EdgeFalling are meant for 1 bit variables:
+// a rising edge is a change from 0 to non 0, a falling edge from non 0 to 0.
+const (
+	EdgeAny Edge = iota
+	EdgeRising
+	EdgeFalling
+)
+
+func (e Edge) match(old, cur uint32) bool {
+	switch e {
+	case EdgeRising:
+		return old == 0 && cur != 0
+	case EdgeFalling:
+		return old != 0 && cur == 0
+	default:
+		return true
+	}
+}
+
+// ChangeEvent is a change of a variable detected by a Scanner.
+type ChangeEvent struct {
+	Name     string
+	Old, New uint32
+	Time     time.Time // time of the snapshot in which the change was detected
+	Cycle    uint64    // cycle of the snapshot in which the change was detected
+}
+
+// Subscription delivers the changes of one variable, see Scanner.Subscribe.
+type Subscription struct {
+	C <-chan ChangeEvent
+
+	name    string
+	edge    Edge
+	c       chan ChangeEvent
+	scanner *Scanner
+}
+
+// Unsubscribe stops the delivery of events and closes C.
+func (s *Subscription) Unsubscribe() {
+	s.scanner.unsubscribe(s)
+}
+
+// Scanner polls the process image at a fixed period and notifies subscribers of variable changes.
+// Each cycle takes one snapshot of the process image, so that short pulses are seen consistently
+// by all subscribers as long as they last at least one period.
+type Scanner struct {
+	ctrl   *RevPiControl
+	period time.Duration
+
+	mu      sync.Mutex
+	subs    map[string][]*Subscription
+	last    *Snapshot
+	err     error
+	stopped bool // Run has returned and closed the subscriptions
+}
+
+// errScannerStopped is returned by Subscribe and Run after Run has returned.
+var errScannerStopped = errors.New("scanner stopped")
+
+// NewScanner creates a scanner polling the process image of c every period, which must be positive.
+func NewScanner(c *RevPiControl, period time.Duration) *Scanner {
+	return &Scanner{ctrl: c, period: period, subs: map[string][]*Subscription{}}
+}
+
+// Subscribe returns a subscription to the changes of a variable, filtered by edge.
+// The channel is buffered with size buffer, events are dropped if the subscriber does not keep up.
+// Subscribe fails after Run has returned.
+func (s *Scanner) Subscribe(name string, edge Edge, buffer int) (*Subscription, error) {
+	vars, err := s.ctrl.variables()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := vars.byName[name]; !ok {
+		return nil, variableNotFound(name)
+	}
+
+	c := make(chan ChangeEvent, buffer)
+	sub := &Subscription{C: c, name: name, edge: edge, c: c, scanner: s}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return nil, errScannerStopped
+	}
+	s.subs[name] = append(s.subs[name], sub)
+	return sub, nil
+}
+
+func (s *Scanner) unsubscribe(sub *Subscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := s.subs[sub.name]
+	for i, x := range subs {
+		if x == sub {
+			s.subs[sub.name] = append(subs[:i:i], subs[i+1:]...)
+			close(sub.c)
+			return
+		}
+	}
+}
+
+// Last returns the most recent snapshot, nil before the first cycle.
+func (s *Scanner) Last() *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// Err returns the error of the last cycle, if any.
+func (s *Scanner) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Run polls the process image until ctx is done, then closes all subscriptions.
+// Read errors are kept in Err and do not stop the scanner. A scanner can be run only once.
+func (s *Scanner) Run(ctx context.Context) error {
+	if s.period <= 0 {
+		return fmt.Errorf("invalid scanner period %v", s.period)
+	}
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped {
+		return errScannerStopped
+	}
+
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+	defer s.closeAll()
+
+	for {
+		s.Scan()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan runs one cycle: it takes a snapshot and notifies the subscribers of the changes since the previous cycle.
+func (s *Scanner) Scan() {
+	snap, err := s.ctrl.Snapshot()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+	if err != nil {
+		return
+	}
+
+	prev := s.last
+	s.last = snap
+	if prev == nil {
+		return
+	}
+	for _, ch := range snap.Diff(prev) {
+		for _, sub := range s.subs[ch.Variable.Name] {
+			if !sub.edge.match(ch.Old, ch.New) {
+				continue
+			}
+			select {
+			case sub.c <- ChangeEvent{Name: ch.Variable.Name, Old: ch.Old, New: ch.New, Time: snap.Time, Cycle: snap.Cycle}:
+			default:
+			}
+		}
+	}
+}
+
+func (s *Scanner) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	for name, subs := range s.subs {
+		for _, sub := range subs {
+			close(sub.c)
+		}
+		delete(s.subs, name)
+	}
+}
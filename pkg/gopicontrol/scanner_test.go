@@ -0,0 +1,51 @@
+package gopicontrol
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScannerInvalidPeriod(t *testing.T) {
+	s := NewScanner(NewRevPiControlWithBackend(newFakeBackend()), 0)
+	if err := s.Run(context.Background()); err == nil {
+		t.Fatal("got no error running a scanner with period 0")
+	}
+}
+
+func TestScannerSubscribe(t *testing.T) {
+	c := NewRevPiControlWithBackend(newFakeBackend())
+	s := NewScanner(c, 5*time.Millisecond)
+	sub, err := s.Subscribe("I_2", EdgeRising, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	for s.Last() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if err = c.SetBitValue(&SPIValue{I16uAddress: 0, I8uBit: 1, I8uValue: 1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-sub.C:
+		if e.Name != "I_2" || e.Old != 0 || e.New != 1 {
+			t.Errorf("got %+v, want a rising edge of I_2", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no change event")
+	}
+
+	cancel()
+	<-done
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription not closed after Run returned")
+	}
+	if _, err = s.Subscribe("I_1", EdgeAny, 1); err == nil {
+		t.Error("got no error subscribing after Run returned")
+	}
+}
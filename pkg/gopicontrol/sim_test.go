@@ -0,0 +1,156 @@
+package gopicontrol_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+	"github.com/mezzato/revpi/pkg/pisim"
+)
+
+// simConfig is a RevPi Core at address 0, a DIO at address 32 and a DI at address 33. The DIO outputs
+// are laid out as O_1, O_2 (exported) and O_3 (not exported) in the bits of offset 16, PWM_1 (exported,
+// 8 bit) at 17, Value (exported, 16 bit) at 18 and Spare (not exported, 16 bit) at 20.
+// The DI has its inputs at 22 and its first counter at 28.
+const simConfig = `{
+ "App": {"name": "PiCtory", "version": "1.4.0"},
+ "Summary": {"inpTotal": 18, "outTotal": 7},
+ "Devices": [
+  {
+   "GUID": "core", "id": "device_RevPiCore", "type": "BASE", "productType": "95", "position": "0",
+   "name": "RevPi Core", "offset": 0,
+   "inp": {"0": ["RevPiStatus", "0", "8", "0", false, "0000", "", ""]},
+   "out": {"0": ["RevPiLED", "0", "8", "1", false, "0001", "", ""]},
+   "mem": {}
+  },
+  {
+   "GUID": "dio", "id": "device_RevPiDIO", "type": "RIGHT", "productType": "96", "position": "32",
+   "name": "RevPi DIO", "offset": 2,
+   "inp": {
+    "0": ["I_1", "0", "1", "0", true, "0000", "", "0"],
+    "1": ["I_2", "0", "1", "0", true, "0001", "", "1"],
+    "2": ["InputStatus", "0", "16", "2", false, "0002", "", ""],
+    "3": ["OutputStatus", "0", "16", "4", false, "0003", "", ""],
+    "4": ["Counter_1", "0", "32", "6", false, "0004", "", ""],
+    "5": ["Counter_2", "0", "32", "10", false, "0005", "", ""]
+   },
+   "out": {
+    "0": ["O_1", "1", "1", "14", true, "0006", "", "0"],
+    "1": ["O_2", "0", "1", "14", true, "0007", "", "1"],
+    "2": ["O_3", "0", "1", "14", false, "0008", "", "2"],
+    "3": ["PWM_1", "0", "8", "15", true, "0009", "", ""],
+    "4": ["Value", "500", "16", "16", true, "0010", "", ""],
+    "5": ["Spare", "0", "16", "18", false, "0011", "", ""]
+   },
+   "mem": {}
+  },
+  {
+   "GUID": "di", "id": "device_RevPiDI", "type": "RIGHT", "productType": "97", "position": "33",
+   "name": "RevPi DI", "offset": 22,
+   "inp": {
+    "0": ["DI_Inputs", "0", "16", "0", false, "0000", "", ""],
+    "1": ["DI_Counter_1", "0", "32", "6", false, "0001", "", ""]
+   },
+   "out": {},
+   "mem": {}
+  }
+ ]
+}`
+
+// countingBackend is a simulator counting the process image calls.
+type countingBackend struct {
+	*pisim.Simulator
+
+	mu                    sync.Mutex
+	reads, writes, setBit int
+	entryLists            int
+	counterResets         []counterReset
+	eventWaits            int
+}
+
+// counterReset is a ResetCounter call.
+type counterReset struct {
+	address  uint8
+	bitfield uint16
+}
+
+func (b *countingBackend) ResetCounter(address uint8, bitfield uint16) (int, error) {
+	b.mu.Lock()
+	b.counterResets = append(b.counterResets, counterReset{address, bitfield})
+	b.mu.Unlock()
+	return b.Simulator.ResetCounter(address, bitfield)
+}
+
+func (b *countingBackend) GetEntryInfoList() ([]gopicontrol.SEntryInfo, error) {
+	b.mu.Lock()
+	b.entryLists++
+	b.mu.Unlock()
+	return b.Simulator.GetEntryInfoList()
+}
+
+func (b *countingBackend) Read(offset uint32, data []byte) (int, error) {
+	b.mu.Lock()
+	b.reads++
+	b.mu.Unlock()
+	return b.Simulator.Read(offset, data)
+}
+
+func (b *countingBackend) Write(offset uint32, data []byte) (int, error) {
+	b.mu.Lock()
+	b.writes++
+	b.mu.Unlock()
+	return b.Simulator.Write(offset, data)
+}
+
+func (b *countingBackend) SetBitValue(v *gopicontrol.SPIValue) error {
+	b.mu.Lock()
+	b.setBit++
+	b.mu.Unlock()
+	return b.Simulator.SetBitValue(v)
+}
+
+func (b *countingBackend) WaitForEvent() (int, error) {
+	b.mu.Lock()
+	b.eventWaits++
+	b.mu.Unlock()
+	return b.Simulator.WaitForEvent()
+}
+
+// counts returns the number of Read, Write and SetBitValue calls and clears them.
+func (b *countingBackend) counts() (reads, writes, setBit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	reads, writes, setBit = b.reads, b.writes, b.setBit
+	b.reads, b.writes, b.setBit = 0, 0, 0
+	return
+}
+
+// newSimControl returns a RevPiControl on a counting simulator loaded with simConfig.
+func newSimControl(t *testing.T) (*gopicontrol.RevPiControl, *countingBackend) {
+	t.Helper()
+	sim, err := pisim.New(strings.NewReader(simConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &countingBackend{Simulator: sim}
+	return gopicontrol.NewRevPiControlWithBackend(b), b
+}
+
+// value returns the simulated value of a variable.
+func value(t *testing.T, b *countingBackend, name string) uint32 {
+	t.Helper()
+	v, err := b.Value(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// setValue sets the simulated value of a variable.
+func setValue(t *testing.T, b *countingBackend, name string, v uint32) {
+	t.Helper()
+	if err := b.SetValue(name, v); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,184 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Region is a contiguous area of the process image.
+type Region struct {
+	Offset uint32 `json:"offset"`
+	Length uint32 `json:"length"`
+}
+
+// InputRegion returns the region of the inputs of a device.
+func InputRegion(d SDeviceInfo) Region {
+	return Region{Offset: uint32(d.I16uInputOffset), Length: uint32(d.I16uInputLength)}
+}
+
+// OutputRegion returns the region of the outputs of a device.
+func OutputRegion(d SDeviceInfo) Region {
+	return Region{Offset: uint32(d.I16uOutputOffset), Length: uint32(d.I16uOutputLength)}
+}
+
+func (r Region) contains(offset, length uint32) bool {
+	return offset >= r.Offset && offset+length <= r.Offset+r.Length
+}
+
+// Snapshot is a copy of the process image, or of some regions of it, taken at one point in time.
+// Logic evaluated on a snapshot sees consistent values instead of values read at different times.
+type Snapshot struct {
+	Time    time.Time // time of the snapshot, with a monotonic clock reading
+	Cycle   uint64    // sequence number of the snapshot for the RevPiControl object
+	Regions []Region  // captured regions
+
+	data []byte
+	vars *variableTable
+}
+
+// Snapshot reads the whole process image with a single read.
+func (c *RevPiControl) Snapshot() (*Snapshot, error) {
+	return c.SnapshotRegions(Region{Offset: 0, Length: ProcessImageLength})
+}
+
+// SnapshotRegions reads the given regions of the process image, e.g. InputRegion of some devices.
+func (c *RevPiControl) SnapshotRegions(regions ...Region) (*Snapshot, error) {
+	vars, err := c.variables()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Snapshot{
+		Regions: append([]Region(nil), regions...),
+		data:    make([]byte, ProcessImageLength),
+		vars:    vars,
+	}
+	for _, r := range regions {
+		if r.Offset+r.Length > ProcessImageLength {
+			return nil, fmt.Errorf("region at offset %d with length %d exceeds the process image", r.Offset, r.Length)
+		}
+		if r.Length == 0 {
+			continue
+		}
+		n, err := c.Read(r.Offset, s.data[r.Offset:r.Offset+r.Length])
+		if err != nil {
+			return nil, err
+		}
+		if n != int(r.Length) {
+			return nil, fmt.Errorf("could not read %d bytes at offset %d: %d bytes read", r.Length, r.Offset, n)
+		}
+	}
+	s.Time = time.Now()
+	s.Cycle = atomic.AddUint64(&c.cycle, 1)
+	return s, nil
+}
+
+// Bytes returns the captured bytes at offset.
+func (s *Snapshot) Bytes(offset, length uint32) ([]byte, error) {
+	if !s.covers(offset, length) {
+		return nil, fmt.Errorf("%d bytes at offset %d are not part of the snapshot", length, offset)
+	}
+	return s.data[offset : offset+length], nil
+}
+
+// Bit returns the value of a 1 bit variable.
+func (s *Snapshot) Bit(name string) (bool, error) {
+	v, err := s.variable(name)
+	if err != nil {
+		return false, err
+	}
+	if !v.IsBit() {
+		return false, fmt.Errorf("variable %s has %d bits and is not a bit", name, v.BitLength)
+	}
+	return s.raw(v) != 0, nil
+}
+
+// Value returns the unsigned value of a variable of 1, 8, 16 or 32 bits.
+func (s *Snapshot) Value(name string) (uint32, error) {
+	v, err := s.variable(name)
+	if err != nil {
+		return 0, err
+	}
+	return s.raw(v), nil
+}
+
+// Signed returns the value of a variable of 8, 16 or 32 bits interpreted as a signed integer.
+func (s *Snapshot) Signed(name string) (int32, error) {
+	v, err := s.variable(name)
+	if err != nil {
+		return 0, err
+	}
+	raw := s.raw(v)
+	switch v.BitLength {
+	case 8:
+		return int32(int8(raw)), nil
+	case 16:
+		return int32(int16(raw)), nil
+	case 32:
+		return int32(raw), nil
+	}
+	return 0, fmt.Errorf("variable %s has %d bits and cannot be read as signed", name, v.BitLength)
+}
+
+// Change is a variable with a different value in two snapshots.
+type Change struct {
+	Variable Variable
+	Old, New uint32
+}
+
+// Diff returns the variables captured in both snapshots whose value differs from prev.
+func (s *Snapshot) Diff(prev *Snapshot) []Change {
+	var changes []Change
+	for i := range s.vars.list {
+		v := &s.vars.list[i]
+		if !supportedLength(v.BitLength) || !s.covers(uint32(v.Offset), variableSize(v)) ||
+			!prev.covers(uint32(v.Offset), variableSize(v)) {
+			continue
+		}
+		if old, cur := prev.raw(v), s.raw(v); old != cur {
+			changes = append(changes, Change{Variable: *v, Old: old, New: cur})
+		}
+	}
+	return changes
+}
+
+func (s *Snapshot) covers(offset, length uint32) bool {
+	for _, r := range s.Regions {
+		if r.contains(offset, length) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Snapshot) variable(name string) (*Variable, error) {
+	v, ok := s.vars.byName[name]
+	if !ok {
+		return nil, variableNotFound(name)
+	}
+	if !supportedLength(v.BitLength) {
+		return nil, fmt.Errorf("variable %s has an unsupported length of %d bits", name, v.BitLength)
+	}
+	if !s.covers(uint32(v.Offset), variableSize(v)) {
+		return nil, fmt.Errorf("variable %s is not part of the snapshot", name)
+	}
+	return v, nil
+}
+
+// raw returns the value of v, which must be covered by the snapshot.
+func (s *Snapshot) raw(v *Variable) uint32 {
+	if v.IsBit() {
+		return uint32(s.data[v.Offset]>>v.Bit) & 1
+	}
+	return leUint32(s.data[uint32(v.Offset) : uint32(v.Offset)+variableSize(v)])
+}
+
+func supportedLength(bits uint16) bool {
+	return bits == 1 || bits == 8 || bits == 16 || bits == 32
+}
+
+// variableSize returns the number of bytes spanned by a variable.
+func variableSize(v *Variable) uint32 {
+	return (uint32(v.BitLength) + 7) / 8
+}
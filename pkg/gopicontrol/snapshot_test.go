@@ -0,0 +1,91 @@
+package gopicontrol_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	c, b := newSimControl(t)
+	setValue(t, b, "I_1", 1)
+	setValue(t, b, "Counter_1", 0x00ffffff)
+	prev, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// I_2 shares its byte with I_1 and O_2 with O_1 and O_3, the counter changes in all bytes
+	setValue(t, b, "I_2", 1)
+	setValue(t, b, "O_2", 1)
+	setValue(t, b, "Counter_1", 0x01000000)
+	setValue(t, b, "Value", 0xfffe)
+	s, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Cycle != prev.Cycle+1 {
+		t.Errorf("got cycle %d after %d", s.Cycle, prev.Cycle)
+	}
+
+	want := map[string][2]uint32{
+		"I_2":       {0, 1},
+		"Counter_1": {0x00ffffff, 0x01000000},
+		"O_2":       {0, 1},
+		"Value":     {500, 0xfffe},
+	}
+	changes := s.Diff(prev)
+	if len(changes) != len(want) {
+		t.Errorf("got %d changes %+v, want %d", len(changes), changes, len(want))
+	}
+	for _, ch := range changes {
+		w, ok := want[ch.Variable.Name]
+		if !ok || ch.Old != w[0] || ch.New != w[1] {
+			t.Errorf("got change of %s from 0x%x to 0x%x, want %v", ch.Variable.Name, ch.Old, ch.New, w)
+		}
+	}
+
+	if v, err := s.Signed("Value"); err != nil || v != -2 {
+		t.Errorf("got signed Value %d, %v, want -2", v, err)
+	}
+	if on, err := s.Bit("I_1"); err != nil || !on {
+		t.Errorf("got I_1 %t, %v, want true", on, err)
+	}
+	if _, err = s.Bit("Value"); err == nil {
+		t.Error("reading the 16 bit Value as a bit succeeded")
+	}
+	if _, err = s.Value("Missing"); !errors.Is(err, gopicontrol.ErrVariableNotFound) {
+		t.Errorf("got %v, want ErrVariableNotFound", err)
+	}
+}
+
+func TestSnapshotRegions(t *testing.T) {
+	c, b := newSimControl(t)
+	dio, err := c.DeviceByAddress(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := dio.Input
+	prev, err := c.SnapshotRegions(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setValue(t, b, "InputStatus", 0x0102)
+	setValue(t, b, "Value", 1000)
+	s, err := c.SnapshotRegions(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := s.Diff(prev)
+	if len(changes) != 1 || changes[0].Variable.Name != "InputStatus" || changes[0].New != 0x0102 {
+		t.Errorf("got changes %+v, want only InputStatus", changes)
+	}
+	if _, err = s.Value("Value"); err == nil {
+		t.Error("reading an output from a snapshot of the inputs succeeded")
+	}
+	if _, err = c.SnapshotRegions(gopicontrol.Region{Offset: gopicontrol.ProcessImageLength - 1, Length: 2}); err == nil {
+		t.Error("snapshot of a region past the process image succeeded")
+	}
+}
@@ -0,0 +1,186 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Tx stages output changes and writes them together on Commit, so that the byte outputs of a module
+// change in the same driver cycle instead of one variable after the other.
+// Bits of partially staged bytes are set one by one, see Commit.
+// A Tx is not safe for concurrent use, commits of different transactions of the same RevPiControl are serialized.
+type Tx struct {
+	ctrl  *RevPiControl
+	bytes map[uint32]stagedByte
+}
+
+// stagedByte is a byte of the process image with the bits in mask to be set to value.
+type stagedByte struct {
+	mask, value byte
+}
+
+// Begin starts a transaction on the process image.
+func (c *RevPiControl) Begin() *Tx {
+	return &Tx{ctrl: c, bytes: map[uint32]stagedByte{}}
+}
+
+// SetBool stages the value of a 1 bit variable.
+func (tx *Tx) SetBool(name string, v bool) error { return txSet(tx, name, v) }
+
+// SetUint8 stages the value of an unsigned 8 bit variable.
+func (tx *Tx) SetUint8(name string, v uint8) error { return txSet(tx, name, v) }
+
+// SetInt8 stages the value of a signed 8 bit variable.
+func (tx *Tx) SetInt8(name string, v int8) error { return txSet(tx, name, v) }
+
+// SetUint16 stages the value of an unsigned 16 bit variable.
+func (tx *Tx) SetUint16(name string, v uint16) error { return txSet(tx, name, v) }
+
+// SetInt16 stages the value of a signed 16 bit variable.
+func (tx *Tx) SetInt16(name string, v int16) error { return txSet(tx, name, v) }
+
+// SetUint32 stages the value of an unsigned 32 bit variable.
+func (tx *Tx) SetUint32(name string, v uint32) error { return txSet(tx, name, v) }
+
+// SetInt32 stages the value of a signed 32 bit variable.
+func (tx *Tx) SetInt32(name string, v int32) error { return txSet(tx, name, v) }
+
+// SetBit stages the value of bit (0-7) of the byte at offset.
+func (tx *Tx) SetBit(offset uint32, bit uint8, v bool) error {
+	if offset >= ProcessImageLength || bit > 7 {
+		return fmt.Errorf("invalid bit %d at offset %d", bit, offset)
+	}
+	var value byte
+	if v {
+		value = 1
+	}
+	tx.stage(offset, 1<<bit, value<<bit)
+	return nil
+}
+
+// SetBytes stages whole bytes at offset.
+func (tx *Tx) SetBytes(offset uint32, data []byte) error {
+	if offset+uint32(len(data)) > ProcessImageLength {
+		return fmt.Errorf("%d bytes at offset %d exceed the process image", len(data), offset)
+	}
+	for i, b := range data {
+		tx.stage(offset+uint32(i), 0xff, b)
+	}
+	return nil
+}
+
+// Len returns the number of staged bytes.
+func (tx *Tx) Len() int {
+	return len(tx.bytes)
+}
+
+// Discard drops the staged changes.
+func (tx *Tx) Discard() {
+	tx.bytes = map[uint32]stagedByte{}
+}
+
+// Commit writes the staged whole bytes merged into contiguous ranges, one Write call per range, and then
+// the bits of partially staged bytes with one KB_SET_VALUE call per bit, which the driver performs atomically
+// leaving the other bits of the byte unchanged. The bits are therefore not set in the same driver cycle.
+// After a successful commit the transaction is empty and can be reused.
+func (tx *Tx) Commit() error {
+	if len(tx.bytes) == 0 {
+		return nil
+	}
+
+	offsets := make([]uint32, 0, len(tx.bytes))
+	for o := range tx.bytes {
+		offsets = append(offsets, o)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	var whole []uint32
+	for _, o := range offsets {
+		if tx.bytes[o].mask == 0xff {
+			whole = append(whole, o)
+		}
+	}
+
+	// serialize with the other commits
+	tx.ctrl.txMu.Lock()
+	defer tx.ctrl.txMu.Unlock()
+
+	for start := 0; start < len(whole); {
+		end := start + 1
+		for end < len(whole) && whole[end] == whole[end-1]+1 {
+			end++
+		}
+		if err := tx.commitRange(whole[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	for _, o := range offsets {
+		if b := tx.bytes[o]; b.mask != 0xff {
+			if err := tx.commitBits(o, b); err != nil {
+				return err
+			}
+		}
+	}
+	tx.Discard()
+	return nil
+}
+
+// commitRange writes the staged whole bytes at the contiguous offsets.
+func (tx *Tx) commitRange(offsets []uint32) error {
+	first := offsets[0]
+	data := make([]byte, len(offsets))
+	for i, o := range offsets {
+		data[i] = tx.bytes[o].value
+	}
+	n, err := tx.ctrl.Write(first, data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("could not write %d bytes at offset %d: %d bytes written", len(data), first, n)
+	}
+	return nil
+}
+
+// commitBits sets the staged bits of the byte at offset.
+func (tx *Tx) commitBits(offset uint32, b stagedByte) error {
+	for bit := uint8(0); bit < 8; bit++ {
+		if b.mask&(1<<bit) == 0 {
+			continue
+		}
+		v := SPIValue{I16uAddress: uint16(offset), I8uBit: bit, I8uValue: b.value >> bit & 1}
+		if err := tx.ctrl.SetBitValue(&v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *Tx) stage(offset uint32, mask, value byte) {
+	b := tx.bytes[offset]
+	b.value = b.value&^mask | value&mask
+	b.mask |= mask
+	tx.bytes[offset] = b
+}
+
+// txSet resolves the variable name and stages v.
+func txSet[T Value](tx *Tx, name string, v T) error {
+	info, err := tx.ctrl.GetVariableInfo(name)
+	if err != nil {
+		return err
+	}
+	if bits := valueBits[T](); int(info.I16uLength) != bits {
+		return fmt.Errorf("variable %s has %d bits and cannot be set as %T (%d bits)", name, info.I16uLength, v, bits)
+	}
+
+	offset := uint32(info.I16uAddress) + uint32(info.I8uBit/8)
+	raw := encodeValue(v)
+	if info.I16uLength == 1 {
+		return tx.SetBit(offset, info.I8uBit%8, raw != 0)
+	}
+	data := make([]byte, info.I16uLength/8)
+	for i := range data {
+		data[i] = byte(raw >> (8 * uint(i)))
+	}
+	return tx.SetBytes(offset, data)
+}
@@ -0,0 +1,120 @@
+package gopicontrol_test
+
+import (
+	"testing"
+)
+
+func TestTxMergesRanges(t *testing.T) {
+	c, b := newSimControl(t)
+	tx := c.Begin()
+	for _, set := range []func() error{
+		func() error { return tx.SetUint16("Spare", 0x1234) },
+		func() error { return tx.SetUint8("PWM_1", 40) },
+		func() error { return tx.SetUint16("Value", 1000) },
+		func() error { return tx.SetUint8("RevPiLED", 3) },
+	} {
+		if err := set(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tx.Len() != 6 {
+		t.Errorf("got %d staged bytes, want 6", tx.Len())
+	}
+	b.counts()
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if reads, writes, setBit := b.counts(); reads != 0 || writes != 2 || setBit != 0 {
+		t.Errorf("got %d reads, %d writes and %d bit sets, want 0, 2 and 0", reads, writes, setBit)
+	}
+	for name, want := range map[string]uint32{"Spare": 0x1234, "PWM_1": 40, "Value": 1000, "RevPiLED": 3} {
+		if got := value(t, b, name); got != want {
+			t.Errorf("got %s %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestTxPartialBytePreservesBits(t *testing.T) {
+	c, b := newSimControl(t)
+	setValue(t, b, "O_3", 1)
+	tx := c.Begin()
+	if err := tx.SetBool("O_1", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetBool("O_2", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.SetUint8("PWM_1", 40); err != nil {
+		t.Fatal(err)
+	}
+	b.counts()
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if reads, writes, setBit := b.counts(); reads != 0 || writes != 1 || setBit != 2 {
+		t.Errorf("got %d reads, %d writes and %d bit sets, want 0, 1 and 2", reads, writes, setBit)
+	}
+	for name, want := range map[string]uint32{"O_1": 0, "O_2": 1, "O_3": 1, "PWM_1": 40} {
+		if got := value(t, b, name); got != want {
+			t.Errorf("got %s %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestTxLengthMismatch(t *testing.T) {
+	c, _ := newSimControl(t)
+	tx := c.Begin()
+	for name, set := range map[string]func() error{
+		"uint8 Value":  func() error { return tx.SetUint8("Value", 1) },
+		"int32 Value":  func() error { return tx.SetInt32("Value", 1) },
+		"bool PWM_1":   func() error { return tx.SetBool("PWM_1", true) },
+		"uint16 O_1":   func() error { return tx.SetUint16("O_1", 1) },
+		"int8 Missing": func() error { return tx.SetInt8("Missing", 1) },
+	} {
+		if err := set(); err == nil {
+			t.Errorf("%s succeeded", name)
+		}
+	}
+	if tx.Len() != 0 {
+		t.Errorf("got %d staged bytes after failed sets, want 0", tx.Len())
+	}
+}
+
+func TestTxDiscardAndReuse(t *testing.T) {
+	c, b := newSimControl(t)
+	tx := c.Begin()
+	if err := tx.SetUint16("Value", 1000); err != nil {
+		t.Fatal(err)
+	}
+	tx.Discard()
+	if tx.Len() != 0 {
+		t.Errorf("got %d staged bytes after Discard, want 0", tx.Len())
+	}
+	b.counts()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, writes, setBit := b.counts(); writes != 0 || setBit != 0 {
+		t.Errorf("discarded transaction made %d writes and %d bit sets", writes, setBit)
+	}
+	if got := value(t, b, "Value"); got != 500 {
+		t.Errorf("got Value %d after Discard, want 500", got)
+	}
+
+	for _, want := range []uint16{1000, 2000} {
+		if err := tx.SetUint16("Value", want); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if tx.Len() != 0 {
+			t.Errorf("got %d staged bytes after Commit, want 0", tx.Len())
+		}
+		if got := value(t, b, "Value"); got != uint32(want) {
+			t.Errorf("got Value %d, want %d", got, want)
+		}
+	}
+}
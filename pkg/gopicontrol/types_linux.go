@@ -60,6 +60,13 @@ type SDIOResetCounter struct {
 	I16uBitfield	uint16
 }
 
+type SConfigData struct {
+	BLeft	uint8
+	Pad_cgo_0	[1]byte
+	I16uLen	uint16
+	AcData	[256]uint8
+}
+
 const (
 	PICONTROL_DEVICE		= "/dev/piControl0"
 	KB_RESET			= 0x4b0c
@@ -68,15 +75,53 @@ const (
 	KB_GET_VALUE			= 0x4b0f
 	KB_SET_VALUE			= 0x4b10
 	KB_FIND_VARIABLE		= 0x4b11
+	KB_SET_EXPORTED_OUTPUTS		= 0x4b12
 	KB_DIO_RESET_COUNTER		= 0x4b14
 	KB_UPDATE_DEVICE_FIRMWARE	= 0x4b13
 	KB_GET_LAST_MESSAGE		= 0x4b15
+	KB_STOP_IO			= 0x4b16
+	KB_CONFIG_STOP			= 0x4b17
+	KB_CONFIG_SEND			= 0x4b18
+	KB_CONFIG_START			= 0x4b19
+	KB_SET_OUTPUT_WATCHDOG		= 0x4b1a
 	KB_INTERN_IO_MSG		= 0x4b65
 	KB_WAIT_FOR_EVENT		= 0x4b32
+	KB_EVENT_RESET			= 0x1
+	CONFIG_DATA_SIZE		= 0x100
+	REV_PI_ERROR_MSG_LEN		= 0x100
+	REV_PI_DEV_FIRST_RIGHT		= 0x20
+	REV_PI_DEV_CNT_MAX		= 0x40
+	PICONTROL_STATUS_RUNNING	= 0x1
+	PICONTROL_STATUS_EXTRA_MODULE	= 0x2
+	PICONTROL_STATUS_MISSING_MODULE	= 0x4
+	PICONTROL_STATUS_SIZE_MISMATCH	= 0x8
+	PICONTROL_STATUS_LEFT_GATEWAY	= 0x10
+	PICONTROL_STATUS_RIGHT_GATEWAY	= 0x20
+	PICONTROL_STATUS_X2_DIN		= 0x40
+	PICONTROL_LED_A1_GREEN		= 0x1
+	PICONTROL_LED_A1_RED		= 0x2
+	PICONTROL_LED_A2_GREEN		= 0x4
+	PICONTROL_LED_A2_RED		= 0x8
+	PICONTROL_LED_A3_GREEN		= 0x10
+	PICONTROL_LED_A3_RED		= 0x20
+	PICONTROL_X2_DOUT		= 0x40
+	PICONTROL_WD_TRIGGER		= 0x80
+	PICONTROL_CONFIG_ERROR_WRONG_MODULE_TYPE	= -0xa
+	PICONTROL_CONFIG_ERROR_WRONG_INPUT_LENGTH	= -0xb
+	PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_LENGTH	= -0xc
+	PICONTROL_CONFIG_ERROR_WRONG_CONFIG_LENGTH	= -0xd
+	PICONTROL_CONFIG_ERROR_WRONG_INPUT_OFFSET	= -0xe
+	PICONTROL_CONFIG_ERROR_WRONG_OUTPUT_OFFSET	= -0xf
+	PICONTROL_CONFIG_ERROR_WRONG_CONFIG_OFFSET	= -0x10
 	PICONTROL_NOT_CONNECTED		= 0x8000
 	PICONTROL_NOT_CONNECTED_MASK	= 0x7fff
+	PICONTROL_SW_OFFSET		= 0x6001
 	PICONTROL_SW_MODBUS_TCP_SLAVE	= 0x6001
 	PICONTROL_SW_MODBUS_RTU_SLAVE	= 0x6002
 	PICONTROL_SW_MODBUS_TCP_MASTER	= 0x6003
 	PICONTROL_SW_MODBUS_RTU_MASTER	= 0x6004
+	PICONTROL_SW_PROFINET_CONTROLLER	= 0x6005
+	PICONTROL_SW_PROFINET_DEVICE	= 0x6006
+	PICONTROL_SW_REVPI_SEVEN	= 0x6007
+	PICONTROL_SW_REVPI_CLOUD	= 0x6008
 )
@@ -0,0 +1,86 @@
+package gopicontrol
+
+// VariableCacheStats are the counters of the variable lookup cache of a RevPiControl.
+type VariableCacheStats struct {
+	Hits    uint64 // lookups answered from the cache
+	Misses  uint64 // lookups forwarded to the driver
+	Entries int    // variables in the cache
+}
+
+// variableCache maps variable names to their SPIVariable, as returned by KB_FIND_VARIABLE.
+// generation is incremented when the cache is discarded, so that lookups started before
+// a reset do not store pre-reset results.
+type variableCache struct {
+	vars       map[string]SPIVariable
+	hits       uint64
+	misses     uint64
+	generation uint64
+}
+
+// GetVariableInfo gets information about a variable by name.
+// The result is cached, so that only the first lookup of a name calls the driver.
+// The cache is discarded by Reset and by the reset events delivered by Events.
+func (c *RevPiControl) GetVariableInfo(name string) (pSpiVariable *SPIVariable, err error) {
+	c.cacheMu.Lock()
+	if v, ok := c.cache.vars[name]; ok {
+		c.cache.hits++
+		c.cacheMu.Unlock()
+		return &v, nil
+	}
+	c.cache.misses++
+	generation := c.cache.generation
+	c.cacheMu.Unlock()
+
+	if pSpiVariable, err = c.backend.GetVariableInfo(name); err != nil {
+		return nil, err
+	}
+
+	c.cacheMu.Lock()
+	if c.cache.generation == generation {
+		if c.cache.vars == nil {
+			c.cache.vars = map[string]SPIVariable{}
+		}
+		c.cache.vars[name] = *pSpiVariable
+	}
+	c.cacheMu.Unlock()
+	return pSpiVariable, nil
+}
+
+// WarmVariableCache fills the variable lookup cache with all variables of the configuration,
+// e.g. at startup before a time critical loop.
+func (c *RevPiControl) WarmVariableCache() error {
+	c.cacheMu.Lock()
+	generation := c.cache.generation
+	c.cacheMu.Unlock()
+
+	vars, err := c.variables()
+	if err != nil {
+		return err
+	}
+
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	if c.cache.generation != generation {
+		// discarded by a reset meanwhile, the table may be outdated
+		return nil
+	}
+	if c.cache.vars == nil {
+		c.cache.vars = make(map[string]SPIVariable, len(vars.byName))
+	}
+	for name, v := range vars.byName {
+		c.cache.vars[name] = SPIVariable{
+			StrVarName:  ByteToUint8Array([]byte(name)),
+			I16uAddress: v.Offset,
+			I8uBit:      v.Bit,
+			I16uLength:  v.BitLength,
+		}
+	}
+	return nil
+}
+
+// VariableCacheStats returns the counters of the variable lookup cache.
+func (c *RevPiControl) VariableCacheStats() VariableCacheStats {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+	return VariableCacheStats{Hits: c.cache.hits, Misses: c.cache.misses, Entries: len(c.cache.vars)}
+}
@@ -0,0 +1,32 @@
+package gopicontrol
+
+import "testing"
+
+// TestVariableCacheResetDuringLookup checks that a lookup overlapping a reset does not cache its result.
+func TestVariableCacheResetDuringLookup(t *testing.T) {
+	fake := newFakeBackend()
+	c := NewRevPiControlWithBackend(fake)
+
+	fake.onLookup = func() {
+		fake.onLookup = nil
+		if err := c.Reset(); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := c.GetVariableInfo("Value"); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.VariableCacheStats().Entries; n != 0 {
+		t.Fatalf("got %d cached variables after a reset during the lookup, want 0", n)
+	}
+
+	if _, err := c.GetVariableInfo("Value"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetVariableInfo("Value"); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.VariableCacheStats(); s.Entries != 1 || s.Hits != 1 || s.Misses != 2 {
+		t.Errorf("got %+v, want 1 entry, 1 hit and 2 misses", s)
+	}
+}
@@ -0,0 +1,219 @@
+package gopicontrol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mezzato/revpi/pkg/piconfig"
+)
+
+// EntryType is the type of a process image entry as stored in SEntryInfo.I8uType.
+type EntryType uint8
+
+// Entry types, EntryExported is added to the type of exported entries.
+const (
+	EntryUndefined EntryType = 0
+	EntryInput     EntryType = 1
+	EntryOutput    EntryType = 2
+	EntryMemory    EntryType = 3
+	EntryConfig    EntryType = 4
+	EntryExported  EntryType = 0x80
+)
+
+// String returns a readable name of the entry type without the exported flag.
+func (t EntryType) String() string {
+	switch t &^ EntryExported {
+	case EntryInput:
+		return "input"
+	case EntryOutput:
+		return "output"
+	case EntryMemory:
+		return "memory"
+	case EntryConfig:
+		return "config"
+	default:
+		return "undefined"
+	}
+}
+
+// Variable is a process image variable with its metadata and owning device.
+type Variable struct {
+	Name      string
+	Type      EntryType // type without the exported flag
+	Exported  bool
+	Index     uint16 // index of the variable in the device
+	Offset    uint16 // offset in the process image
+	Bit       uint8  // bit position 0-7 for 1 bit variables
+	BitLength uint16 // length in bits
+	Default   uint32
+	Device    SDeviceInfo
+}
+
+// IsBit reports whether the variable is a single bit.
+func (v *Variable) IsBit() bool {
+	return v.BitLength == 1
+}
+
+// EntryInfoList builds the piControl table of entries of a piCtory configuration.
+// Entries are ordered by device and then inputs, outputs and memory like the piControl driver,
+// which allows to match them with SDeviceInfo.I16uFirstEntry and I16uEntries.
+func EntryInfoList(cfg *piconfig.Config) ([]SEntryInfo, error) {
+	var entries []SEntryInfo
+	for _, d := range cfg.Devices {
+		for _, e := range d.Entries() {
+			if len(e.Name) >= len(SEntryInfo{}.StrVarName) {
+				return nil, fmt.Errorf("variable name %s is longer than %d characters", e.Name, len(SEntryInfo{}.StrVarName)-1)
+			}
+			if int(e.ImageOffset)+int(e.BitLength+7)/8 > ProcessImageLength {
+				return nil, fmt.Errorf("variable %s at offset %d exceeds the process image", e.Name, e.ImageOffset)
+			}
+			ei := SEntryInfo{
+				I8uAddress:    d.Position,
+				I8uType:       uint8(e.Type),
+				I16uIndex:     uint16(e.Index),
+				I16uBitLength: e.BitLength,
+				I8uBitPos:     e.ImageBit,
+				I16uOffset:    e.ImageOffset,
+				I32uDefault:   uint32(e.Default),
+				StrVarName:    ByteToUint8Array([]byte(e.Name)),
+			}
+			if e.Exported {
+				ei.I8uType |= uint8(EntryExported)
+			}
+			entries = append(entries, ei)
+		}
+	}
+	return entries, nil
+}
+
+// EntryName returns the variable name of an entry.
+func EntryName(e *SEntryInfo) string {
+	return cString(e.StrVarName[:])
+}
+
+// cString converts a NUL terminated byte array to a string.
+func cString(b []uint8) string {
+	s := string(b)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// GetEntryInfoList gets the table of all entries in the process image.
+func (c *RevPiControl) GetEntryInfoList() ([]SEntryInfo, error) {
+	return c.backend.GetEntryInfoList()
+}
+
+// ListVariables returns all variables of the process image with their owning device.
+// The variables are loaded once and reloaded after a reset of the driver.
+func (c *RevPiControl) ListVariables() ([]Variable, error) {
+	t, err := c.variables()
+	if err != nil {
+		return nil, err
+	}
+	return append([]Variable(nil), t.list...), nil
+}
+
+// VariablesOfDevice returns the variables of the device at address.
+func (c *RevPiControl) VariablesOfDevice(address uint8) ([]Variable, error) {
+	t, err := c.variables()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range t.devices {
+		if d.I8uAddress != address {
+			continue
+		}
+		var vars []Variable
+		for _, v := range t.list {
+			if v.Device.I8uAddress == address {
+				vars = append(vars, v)
+			}
+		}
+		return vars, nil
+	}
+	return nil, deviceNotFound(address)
+}
+
+// variableTable is the table of all variables indexed by name.
+type variableTable struct {
+	devices []SDeviceInfo
+	list    []Variable
+	byName  map[string]*Variable
+}
+
+// variables returns the table of all variables, it is loaded once and reloaded after a reset.
+func (c *RevPiControl) variables() (*variableTable, error) {
+	c.varMu.Lock()
+	defer c.varMu.Unlock()
+	if c.vars != nil {
+		return c.vars, nil
+	}
+
+	devices, err := c.GetDeviceInfoList()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.GetEntryInfoList()
+	if err != nil {
+		return nil, err
+	}
+	t := &variableTable{devices: devices, list: make([]Variable, 0, len(entries))}
+	for _, d := range devices {
+		t.list = append(t.list, deviceVariables(d, entries)...)
+	}
+	t.byName = make(map[string]*Variable, len(t.list))
+	for i := range t.list {
+		if _, ok := t.byName[t.list[i].Name]; !ok {
+			t.byName[t.list[i].Name] = &t.list[i]
+		}
+	}
+	c.vars = t
+	return t, nil
+}
+
+// invalidateCaches discards the table of variables, the variable lookup cache and the struct bindings,
+// e.g. after a reset of the driver.
+func (c *RevPiControl) invalidateCaches() {
+	c.varMu.Lock()
+	c.vars = nil
+	c.varMu.Unlock()
+
+	c.cacheMu.Lock()
+	c.cache.vars = nil
+	c.cache.generation++
+	c.cacheMu.Unlock()
+
+	c.bindMu.Lock()
+	c.bindings = nil
+	c.bindMu.Unlock()
+}
+
+// deviceVariables returns the entries of d, from I16uFirstEntry to I16uFirstEntry+I16uEntries.
+func deviceVariables(d SDeviceInfo, entries []SEntryInfo) []Variable {
+	first, last := int(d.I16uFirstEntry), int(d.I16uFirstEntry)+int(d.I16uEntries)
+	if first > len(entries) {
+		return nil
+	}
+	if last > len(entries) {
+		last = len(entries)
+	}
+
+	vars := make([]Variable, 0, last-first)
+	for i := first; i < last; i++ {
+		e := &entries[i]
+		vars = append(vars, Variable{
+			Name:      EntryName(e),
+			Type:      EntryType(e.I8uType) &^ EntryExported,
+			Exported:  EntryType(e.I8uType)&EntryExported != 0,
+			Index:     e.I16uIndex,
+			Offset:    e.I16uOffset,
+			Bit:       e.I8uBitPos,
+			BitLength: e.I16uBitLength,
+			Default:   e.I32uDefault,
+			Device:    d,
+		})
+	}
+	return vars
+}
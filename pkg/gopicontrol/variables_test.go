@@ -0,0 +1,52 @@
+package gopicontrol_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+func TestVariablesLoadedOnce(t *testing.T) {
+	c, b := newSimControl(t)
+	entryLists := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.entryLists
+	}
+
+	for i := 0; i < 2; i++ {
+		vars, err := c.ListVariables()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(vars) != 16 || vars[0].Name != "RevPiStatus" {
+			t.Fatalf("got %d variables starting with %s, want 16 starting with RevPiStatus", len(vars), vars[0].Name)
+		}
+		// the callers get a copy of the table
+		vars[0].Name = "changed"
+	}
+	vars, err := c.VariablesOfDevice(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 12 || vars[0].Name != "I_1" {
+		t.Errorf("got %d variables of the DIO starting with %s, want 12 starting with I_1", len(vars), vars[0].Name)
+	}
+	if _, err = c.VariablesOfDevice(34); !errors.Is(err, gopicontrol.ErrDeviceNotFound) {
+		t.Errorf("got %v, want ErrDeviceNotFound", err)
+	}
+	if n := entryLists(); n != 1 {
+		t.Errorf("got %d entry list calls, want 1", n)
+	}
+
+	if err = c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.ListVariables(); err != nil {
+		t.Fatal(err)
+	}
+	if n := entryLists(); n != 2 {
+		t.Errorf("got %d entry list calls after a reset, want 2", n)
+	}
+}
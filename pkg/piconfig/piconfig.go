@@ -0,0 +1,301 @@
+// Package piconfig parses the piCtory configuration file config.rsc.
+//
+// The configuration is the JSON file written by piCtory and loaded by the piControl driver,
+// it describes the devices of a RevPi system and the process image variables of each device.
+package piconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Locations of the piCtory configuration, see PICONFIG_FILE and PICONFIG_FILE_WHEEZY in piControl.h.
+const (
+	ConfigFile       = "/etc/revpi/config.rsc"
+	ConfigFileWheezy = "/opt/KUNBUS/config.rsc"
+)
+
+// EntryType is the kind of a process image entry.
+type EntryType uint8
+
+// Entry types, the values match SEntryInfo.I8uType without the exported flag.
+const (
+	EntryInput  EntryType = 1
+	EntryOutput EntryType = 2
+	EntryMemory EntryType = 3
+)
+
+// String returns the name of the piCtory section of the entry type.
+func (t EntryType) String() string {
+	switch t {
+	case EntryInput:
+		return "input"
+	case EntryOutput:
+		return "output"
+	case EntryMemory:
+		return "memory"
+	default:
+		return "undefined"
+	}
+}
+
+// Config is a piCtory configuration.
+type Config struct {
+	App         App             `json:"App"`
+	Summary     Summary         `json:"Summary"`
+	Devices     []Device        `json:"Devices"`
+	Connections json.RawMessage `json:"Connections,omitempty"`
+}
+
+// App describes the piCtory version which saved the configuration.
+type App struct {
+	Name     string          `json:"name"`
+	Version  string          `json:"version"`
+	SaveTS   string          `json:"saveTS"`
+	Language string          `json:"language"`
+	Layout   json.RawMessage `json:"layout,omitempty"`
+}
+
+// Summary holds the total sizes of inputs and outputs.
+type Summary struct {
+	InpTotal int `json:"inpTotal"`
+	OutTotal int `json:"outTotal"`
+}
+
+// Device is a module configured in piCtory.
+type Device struct {
+	GUID        string
+	ID          string
+	Type        string // BASE, LEFT_RIGHT, VIRTUAL, ...
+	ProductType uint16 // module type as in SDeviceInfo.I16uModuleType
+	Position    uint8  // address of the module
+	Name        string
+	Bmk         string
+	InpVariant  int
+	OutVariant  int
+	Comment     string
+	Offset      uint16 // offset of the device in the process image
+	Inputs      []Entry
+	Outputs     []Entry
+	Memory      []Entry
+	Extend      json.RawMessage
+}
+
+// Entry is an input, output or memory variable of a device.
+type Entry struct {
+	Type        EntryType
+	Index       int // index of the entry in the device section
+	Name        string
+	DefaultText string // default value as written by piCtory
+	Default     int64  // numeric default value, 0 if DefaultText is not a number
+	BitLength   uint16
+	Offset      uint16 // offset relative to the device
+	Exported    bool
+	SortOrder   string
+	Comment     string
+	BitPosition uint8 // bit position relative to Offset, can be greater than 7
+
+	// ImageOffset and ImageBit are the absolute position in the process image,
+	// with the bit position normalized to 0-7.
+	ImageOffset uint16
+	ImageBit    uint8
+}
+
+// Entries returns all entries of the device: inputs, outputs, memory.
+func (d *Device) Entries() []Entry {
+	entries := make([]Entry, 0, len(d.Inputs)+len(d.Outputs)+len(d.Memory))
+	entries = append(entries, d.Inputs...)
+	entries = append(entries, d.Outputs...)
+	return append(entries, d.Memory...)
+}
+
+// Device returns the device at the given position (address) or nil.
+func (c *Config) Device(position uint8) *Device {
+	for i := range c.Devices {
+		if c.Devices[i].Position == position {
+			return &c.Devices[i]
+		}
+	}
+	return nil
+}
+
+// Entry looks up an entry by name and returns it with its device.
+func (c *Config) Entry(name string) (*Entry, *Device, bool) {
+	for i := range c.Devices {
+		d := &c.Devices[i]
+		for _, section := range [][]Entry{d.Inputs, d.Outputs, d.Memory} {
+			for j := range section {
+				if section[j].Name == name {
+					return &section[j], d, true
+				}
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// configFiles are the locations read by Load, ConfigFile and its fallback ConfigFileWheezy.
+var configFiles = [2]string{ConfigFile, ConfigFileWheezy}
+
+// Load reads the configuration from ConfigFile, falling back to ConfigFileWheezy if it does not exist.
+func Load() (*Config, error) {
+	c, err := LoadFile(configFiles[0])
+	if os.IsNotExist(err) {
+		return LoadFile(configFiles[1])
+	}
+	return c, err
+}
+
+// LoadFile reads the configuration from a file.
+func LoadFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads the configuration from r.
+func Parse(r io.Reader) (*Config, error) {
+	var c Config
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("could not parse piCtory configuration: %v", err)
+	}
+	return &c, nil
+}
+
+// rscDevice is the JSON layout of a device.
+type rscDevice struct {
+	GUID        string                       `json:"GUID"`
+	ID          string                       `json:"id"`
+	Type        string                       `json:"type"`
+	ProductType number                       `json:"productType"`
+	Position    number                       `json:"position"`
+	Name        string                       `json:"name"`
+	Bmk         string                       `json:"bmk"`
+	InpVariant  number                       `json:"inpVariant"`
+	OutVariant  number                       `json:"outVariant"`
+	Comment     string                       `json:"comment"`
+	Offset      number                       `json:"offset"`
+	Inp         map[string][]json.RawMessage `json:"inp"`
+	Out         map[string][]json.RawMessage `json:"out"`
+	Mem         map[string][]json.RawMessage `json:"mem"`
+	Extend      json.RawMessage              `json:"extend,omitempty"`
+}
+
+// UnmarshalJSON decodes a device and its entries.
+func (d *Device) UnmarshalJSON(b []byte) (err error) {
+	var r rscDevice
+	if err = json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	*d = Device{
+		GUID:        r.GUID,
+		ID:          r.ID,
+		Type:        r.Type,
+		ProductType: uint16(r.ProductType),
+		Position:    uint8(r.Position),
+		Name:        r.Name,
+		Bmk:         r.Bmk,
+		InpVariant:  int(r.InpVariant),
+		OutVariant:  int(r.OutVariant),
+		Comment:     r.Comment,
+		Offset:      uint16(r.Offset),
+		Extend:      r.Extend,
+	}
+	if d.Inputs, err = d.parseEntries(EntryInput, r.Inp); err != nil {
+		return err
+	}
+	if d.Outputs, err = d.parseEntries(EntryOutput, r.Out); err != nil {
+		return err
+	}
+	d.Memory, err = d.parseEntries(EntryMemory, r.Mem)
+	return err
+}
+
+// parseEntries parses a section of entries:
+// {"<index>": [name, default, bit length, offset, exported, sort order, comment, bit position], ...}
+func (d *Device) parseEntries(t EntryType, m map[string][]json.RawMessage) ([]Entry, error) {
+	entries := make([]Entry, 0, len(m))
+	for k, raw := range m {
+		index, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: invalid %s index %q", d.Name, t, k)
+		}
+		if len(raw) < 5 {
+			return nil, fmt.Errorf("device %s: %s %d has only %d fields", d.Name, t, index, len(raw))
+		}
+		e := Entry{Type: t, Index: index}
+		var bitLength, offset, bitPos number
+		fields := []struct {
+			pos int
+			v   interface{}
+		}{
+			{0, &e.Name}, {1, (*text)(&e.DefaultText)}, {2, &bitLength}, {3, &offset},
+			{4, &e.Exported}, {5, (*text)(&e.SortOrder)}, {6, (*text)(&e.Comment)}, {7, &bitPos},
+		}
+		for _, f := range fields {
+			if f.pos >= len(raw) {
+				break
+			}
+			if err := json.Unmarshal(raw[f.pos], f.v); err != nil {
+				return nil, fmt.Errorf("device %s: %s %d: field %d: %v", d.Name, t, index, f.pos, err)
+			}
+		}
+		// piCtory writes decimal values, possibly with leading zeros
+		e.Default, _ = strconv.ParseInt(e.DefaultText, 10, 64)
+		e.BitLength, e.Offset, e.BitPosition = uint16(bitLength), uint16(offset), uint8(bitPos)
+		e.ImageOffset = d.Offset + e.Offset + uint16(e.BitPosition/8)
+		e.ImageBit = e.BitPosition % 8
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Index < entries[j].Index })
+	return entries, nil
+}
+
+// number is an integer which piCtory writes either as a JSON number or as a string.
+type number int64
+
+func (n *number) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var f float64
+		if err := json.Unmarshal(b, &f); err != nil {
+			return err
+		}
+		*n = number(f)
+		return nil
+	}
+	if s == "" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*n = number(v)
+	return nil
+}
+
+// text is a string which piCtory sometimes writes as a JSON number.
+type text string
+
+func (t *text) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = text(s)
+		return nil
+	}
+	var f json.Number
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*t = text(f)
+	return nil
+}
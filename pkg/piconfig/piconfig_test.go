@@ -0,0 +1,175 @@
+package piconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testConfig mixes the number and string encodings piCtory uses for the same fields.
+const testConfig = `{
+ "App": {"name": "PiCtory", "version": "1.4.0"},
+ "Summary": {"inpTotal": 6, "outTotal": 1},
+ "Devices": [
+  {
+   "GUID": "core", "id": "device_RevPiCore", "type": "BASE", "productType": "95", "position": "0",
+   "name": "RevPi Core", "offset": 0,
+   "inp": {"0": ["RevPiStatus", "0", "8", "0", false, "0000", "", ""]},
+   "out": {},
+   "mem": {}
+  },
+  {
+   "GUID": "dio", "id": "device_RevPiDIO", "type": "RIGHT", "productType": 96, "position": "32",
+   "name": "RevPi DIO", "offset": "011",
+   "inp": {
+    "10": ["Counter_1", "0", 32, 6, false, "0010", "", ""],
+    "1": ["I_2", "0", 1, "0", true, "0001", "", "1"],
+    "2": ["I_10", "0", "1", "0", true, "0002", "", "9"],
+    "0": ["I_1", "0", "1", "0", true, "0000", "", "0"]
+   },
+   "out": {
+    "0": ["O_1", 1, "1", "014", true, 6, 300, "0"]
+   },
+   "mem": {
+    "0": ["Delay", "0100", "16", "100", false, "0020", "ms", ""]
+   }
+  }
+ ]
+}`
+
+func parseTestConfig(t *testing.T) *Config {
+	t.Helper()
+	c, err := Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestParseNumbers(t *testing.T) {
+	c := parseTestConfig(t)
+	d := c.Device(32)
+	if d == nil {
+		t.Fatal("device 32 not found")
+	}
+	if d.ProductType != 96 || d.Offset != 11 {
+		t.Errorf("got product type %d at offset %d, want 96 at 11", d.ProductType, d.Offset)
+	}
+	if core := c.Device(0); core == nil || core.ProductType != 95 {
+		t.Errorf("got core %+v, want product type 95", core)
+	}
+
+	e, _, ok := c.Entry("Counter_1")
+	if !ok {
+		t.Fatal("Counter_1 not found")
+	}
+	if e.BitLength != 32 || e.Offset != 6 || e.ImageOffset != 17 {
+		t.Errorf("got Counter_1 with %d bits at %d (%d), want 32 bits at 6 (17)", e.BitLength, e.Offset, e.ImageOffset)
+	}
+
+	// values with leading zeros are decimal
+	e, _, _ = c.Entry("O_1")
+	if e.Offset != 14 || e.ImageOffset != 25 {
+		t.Errorf("got O_1 at %d (%d), want 14 (25)", e.Offset, e.ImageOffset)
+	}
+	e, _, _ = c.Entry("Delay")
+	if e.Type != EntryMemory || e.DefaultText != "0100" || e.Default != 100 {
+		t.Errorf("got Delay %s with default %q (%d), want memory with default 0100 (100)", e.Type, e.DefaultText, e.Default)
+	}
+}
+
+func TestParseNumericText(t *testing.T) {
+	c := parseTestConfig(t)
+	e, _, ok := c.Entry("O_1")
+	if !ok {
+		t.Fatal("O_1 not found")
+	}
+	if e.DefaultText != "1" || e.Default != 1 || e.SortOrder != "6" || e.Comment != "300" {
+		t.Errorf("got default %q (%d), sort order %q and comment %q, want 1 (1), 6 and 300",
+			e.DefaultText, e.Default, e.SortOrder, e.Comment)
+	}
+}
+
+func TestParseBitPosition(t *testing.T) {
+	c := parseTestConfig(t)
+	for _, tc := range []struct {
+		name   string
+		pos    uint8
+		offset uint16
+		bit    uint8
+	}{
+		{"I_1", 0, 11, 0},
+		{"I_2", 1, 11, 1},
+		{"I_10", 9, 12, 1},
+	} {
+		e, _, ok := c.Entry(tc.name)
+		if !ok {
+			t.Fatalf("%s not found", tc.name)
+		}
+		if e.BitPosition != tc.pos || e.ImageOffset != tc.offset || e.ImageBit != tc.bit {
+			t.Errorf("got %s at bit position %d, image offset %d bit %d, want %d, %d and %d",
+				tc.name, e.BitPosition, e.ImageOffset, e.ImageBit, tc.pos, tc.offset, tc.bit)
+		}
+	}
+}
+
+func TestParseIndexOrder(t *testing.T) {
+	d := parseTestConfig(t).Device(32)
+	var names []string
+	for _, e := range d.Inputs {
+		names = append(names, e.Name)
+	}
+	if got, want := strings.Join(names, " "), "I_1 I_2 I_10 Counter_1"; got != want {
+		t.Errorf("got inputs %s, want %s", got, want)
+	}
+
+	var types []string
+	for _, e := range d.Entries() {
+		types = append(types, e.Type.String())
+	}
+	if got, want := strings.Join(types, " "), "input input input input output memory"; got != want {
+		t.Errorf("got entries %s, want %s", got, want)
+	}
+}
+
+func TestParseShortEntry(t *testing.T) {
+	cfg := strings.Replace(testConfig, `["I_1", "0", "1", "0", true, "0000", "", "0"]`, `["I_1", "0", "1", "0"]`, 1)
+	_, err := Parse(strings.NewReader(cfg))
+	if err == nil || !strings.Contains(err.Error(), "input 0 has only 4 fields") {
+		t.Errorf("got %v, want an error about the 4 fields of input 0", err)
+	}
+}
+
+func TestLoadFallback(t *testing.T) {
+	dir := t.TempDir()
+	primary, wheezy := filepath.Join(dir, "config.rsc"), filepath.Join(dir, "wheezy.rsc")
+	saved := configFiles
+	configFiles = [2]string{primary, wheezy}
+	defer func() { configFiles = saved }()
+
+	if _, err := Load(); !os.IsNotExist(err) {
+		t.Fatalf("got %v without configuration files, want a not exist error", err)
+	}
+
+	if err := os.WriteFile(wheezy, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Devices) != 2 {
+		t.Errorf("got %d devices from the fallback, want 2", len(c.Devices))
+	}
+
+	if err = os.WriteFile(primary, []byte(`{"Devices": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Devices) != 0 {
+		t.Errorf("got %d devices, want the primary file with 0", len(c.Devices))
+	}
+}
@@ -0,0 +1,589 @@
+// Package pisim provides an in-memory simulator of the piControl kernel driver.
+//
+// The simulator loads a piCtory configuration (config.rsc), lays out the process image
+// like the kernel module does and implements gopicontrol.Backend, so that applications and
+// tests can use a gopicontrol.RevPiControl without a RevPi:
+//
+//	sim, err := pisim.Load("config.rsc")
+//	if err != nil {
+//		return err
+//	}
+//	ctrl := gopicontrol.NewRevPiControlWithBackend(sim)
+//	sim.SetValue("I_1", 1) // inject an input
+package pisim
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+	"github.com/mezzato/revpi/pkg/piconfig"
+	"golang.org/x/sys/unix"
+)
+
+// driverError returns the error of a failed driver call like the DeviceBackend does.
+func driverError(op string, errno unix.Errno) error {
+	return &gopicontrol.DriverError{Op: op, Errno: errno}
+}
+
+// Simulator is an in-memory gopicontrol.Backend emulating the piControl kernel driver.
+// It is safe for concurrent use.
+type Simulator struct {
+	path string
+
+	mu      sync.Mutex
+	cfg     *piconfig.Config
+	image   [gopicontrol.ProcessImageLength]byte
+	devices []gopicontrol.SDeviceInfo
+	entries []gopicontrol.SEntryInfo
+	names   map[string]int
+	event   chan struct{}
+
+	watchdog        *time.Timer
+	watchdogTimeout time.Duration
+	watchdogExpired bool
+
+	ioStopped bool
+
+	configuring   bool
+	gatewayConfig [2][]byte // configurations downloaded to the right and left gateway
+
+	lastMessage string
+}
+
+// Load creates a simulator from the piCtory configuration file at path.
+// The file is read again on Reset like the kernel driver does.
+func Load(path string) (*Simulator, error) {
+	cfg, err := piconfig.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Simulator{path: path, event: make(chan struct{})}
+	if err = s.configure(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// New creates a simulator from a piCtory configuration read from r.
+func New(r io.Reader) (*Simulator, error) {
+	cfg, err := piconfig.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(cfg)
+}
+
+// NewFromConfig creates a simulator from a parsed piCtory configuration.
+func NewFromConfig(cfg *piconfig.Config) (*Simulator, error) {
+	s := &Simulator{event: make(chan struct{})}
+	if err := s.configure(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// configure lays out the process image for cfg and sets the default values.
+func (s *Simulator) configure(cfg *piconfig.Config) error {
+	var (
+		devices []gopicontrol.SDeviceInfo
+		names   = map[string]int{}
+	)
+
+	if len(cfg.Devices) > gopicontrol.REV_PI_DEV_CNT_MAX {
+		return fmt.Errorf("%d devices configured, the driver supports at most %d", len(cfg.Devices), gopicontrol.REV_PI_DEV_CNT_MAX)
+	}
+	entries, err := gopicontrol.EntryInfoList(cfg)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		name := gopicontrol.EntryName(&entries[i])
+		if _, ok := names[name]; !ok {
+			names[name] = i
+		}
+	}
+
+	first := 0
+	for _, d := range cfg.Devices {
+		dev := gopicontrol.SDeviceInfo{
+			I8uAddress:     d.Position,
+			I16uModuleType: d.ProductType,
+			I16uBaseOffset: d.Offset,
+			I16uFirstEntry: uint16(first),
+			I16uEntries:    uint16(len(d.Inputs) + len(d.Outputs) + len(d.Memory)),
+			I8uActive:      1,
+		}
+		devEntries := entries[first : first+int(dev.I16uEntries)]
+		first += int(dev.I16uEntries)
+
+		// regions follow each other: inputs, outputs, config
+		next := dev.I16uBaseOffset
+		for _, region := range []struct {
+			typ    gopicontrol.EntryType
+			offset *uint16
+			length *uint16
+		}{
+			{gopicontrol.EntryInput, &dev.I16uInputOffset, &dev.I16uInputLength},
+			{gopicontrol.EntryOutput, &dev.I16uOutputOffset, &dev.I16uOutputLength},
+			{gopicontrol.EntryMemory, &dev.I16uConfigOffset, &dev.I16uConfigLength},
+		} {
+			start, end := -1, int(next)
+			for _, e := range devEntries {
+				if gopicontrol.EntryType(e.I8uType)&^gopicontrol.EntryExported != region.typ {
+					continue
+				}
+				if start < 0 || int(e.I16uOffset) < start {
+					start = int(e.I16uOffset)
+				}
+				if size := int(e.I16uBitLength+7) / 8; int(e.I16uOffset)+size > end {
+					end = int(e.I16uOffset) + size
+				}
+			}
+			if start < 0 {
+				start = int(next)
+			}
+			*region.offset = uint16(start)
+			*region.length = uint16(end - start)
+			next = uint16(end)
+		}
+		devices = append(devices, dev)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	s.devices = devices
+	s.entries = entries
+	s.names = names
+	s.image = [gopicontrol.ProcessImageLength]byte{}
+	for i := range s.entries {
+		s.writeEntry(&s.entries[i], s.entries[i].I32uDefault)
+	}
+	if i, ok := s.names[gopicontrol.CoreStatusVariable]; ok {
+		s.writeEntry(&s.entries[i], uint32(coreStatus(devices)))
+	}
+	return nil
+}
+
+// coreStatus returns the status byte of the RevPi Core for the configured devices, which are all present.
+func coreStatus(devices []gopicontrol.SDeviceInfo) uint8 {
+	status := uint8(gopicontrol.PICONTROL_STATUS_RUNNING)
+	for _, d := range devices {
+		if gopicontrol.FamilyOf(d.I16uModuleType) != gopicontrol.FamilyGateway {
+			continue
+		}
+		if d.I8uAddress < gopicontrol.REV_PI_DEV_FIRST_RIGHT {
+			status |= gopicontrol.PICONTROL_STATUS_LEFT_GATEWAY
+		} else {
+			status |= gopicontrol.PICONTROL_STATUS_RIGHT_GATEWAY
+		}
+	}
+	return status
+}
+
+// readEntry reads the value of an entry from the process image, s.mu must be held.
+func (s *Simulator) readEntry(e *gopicontrol.SEntryInfo) uint32 {
+	o := int(e.I16uOffset)
+	switch e.I16uBitLength {
+	case 1:
+		return uint32(s.image[o]>>e.I8uBitPos) & 1
+	case 8:
+		return uint32(s.image[o])
+	case 16:
+		return uint32(binary.LittleEndian.Uint16(s.image[o:]))
+	case 32:
+		return binary.LittleEndian.Uint32(s.image[o:])
+	}
+	return 0
+}
+
+// writeEntry writes the value of an entry to the process image, s.mu must be held.
+// Entries with a length other than 1, 8, 16 or 32 bits are left untouched.
+func (s *Simulator) writeEntry(e *gopicontrol.SEntryInfo, v uint32) {
+	o := int(e.I16uOffset)
+	switch e.I16uBitLength {
+	case 1:
+		if v != 0 {
+			s.image[o] |= 1 << e.I8uBitPos
+		} else {
+			s.image[o] &^= 1 << e.I8uBitPos
+		}
+	case 8:
+		s.image[o] = uint8(v)
+	case 16:
+		binary.LittleEndian.PutUint16(s.image[o:], uint16(v))
+	case 32:
+		binary.LittleEndian.PutUint32(s.image[o:], v)
+	}
+}
+
+// GetEntryInfoList returns the table of entries built from the configuration.
+func (s *Simulator) GetEntryInfoList() ([]gopicontrol.SEntryInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]gopicontrol.SEntryInfo(nil), s.entries...), nil
+}
+
+// Value returns the value of a variable, independently of its direction.
+func (s *Simulator) Value(name string) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.names[name]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", gopicontrol.ErrVariableNotFound, name)
+	}
+	return s.readEntry(&s.entries[i]), nil
+}
+
+// SetValue sets the value of a variable, typically used to inject inputs in tests.
+// Inputs cannot be set while the I/O communication is stopped, see StopIO.
+func (s *Simulator) SetValue(name string, v uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.names[name]
+	if !ok {
+		return fmt.Errorf("%w %s", gopicontrol.ErrVariableNotFound, name)
+	}
+	if (s.ioStopped || s.configuring) && gopicontrol.EntryType(s.entries[i].I8uType)&^gopicontrol.EntryExported == gopicontrol.EntryInput {
+		return fmt.Errorf("could not set input %s: I/O communication is stopped", name)
+	}
+	s.writeEntry(&s.entries[i], v)
+	return nil
+}
+
+// Bytes returns a copy of the whole process image.
+func (s *Simulator) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte(nil), s.image[:]...)
+}
+
+// Open does nothing, the simulator needs no resources.
+func (s *Simulator) Open() error {
+	return nil
+}
+
+// Close does nothing, the simulator keeps its state.
+func (s *Simulator) Close() error {
+	return nil
+}
+
+// Reset reloads the configuration, restores the default values
+// and wakes up all goroutines blocked in WaitForEvent with KB_EVENT_RESET.
+func (s *Simulator) Reset() error {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	if s.path != "" {
+		var err error
+		if cfg, err = piconfig.LoadFile(s.path); err != nil {
+			return err
+		}
+	}
+	if err := s.configure(cfg); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	close(s.event)
+	s.event = make(chan struct{})
+	s.mu.Unlock()
+	return nil
+}
+
+// Read copies the process image starting at offset into pData.
+func (s *Simulator) Read(offset uint32, pData []byte) (n int, err error) {
+	if offset >= gopicontrol.ProcessImageLength {
+		return 0, driverError("read", unix.EFAULT)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copy(pData, s.image[offset:]), nil
+}
+
+// Write copies pData to the process image starting at offset, it also feeds the output watchdog.
+func (s *Simulator) Write(offset uint32, pData []byte) (n int, err error) {
+	if offset >= gopicontrol.ProcessImageLength {
+		return 0, driverError("write", unix.EFAULT)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchdog != nil {
+		s.watchdog.Reset(s.watchdogTimeout)
+	}
+	return copy(s.image[offset:], pData), nil
+}
+
+// SetOutputWatchdog activates the output watchdog: if Write is not called within timeoutMs
+// all outputs are set to 0. A timeout of 0 deactivates the watchdog.
+func (s *Simulator) SetOutputWatchdog(timeoutMs uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchdog != nil {
+		s.watchdog.Stop()
+		s.watchdog = nil
+	}
+	s.watchdogTimeout = time.Duration(timeoutMs) * time.Millisecond
+	s.watchdogExpired = false
+	if timeoutMs > 0 {
+		s.watchdog = time.AfterFunc(s.watchdogTimeout, s.expireWatchdog)
+	}
+	return nil
+}
+
+// WatchdogExpired reports whether the output watchdog has expired since it was activated.
+func (s *Simulator) WatchdogExpired() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watchdogExpired
+}
+
+// expireWatchdog sets all outputs to 0 like the driver does when the watchdog expires.
+func (s *Simulator) expireWatchdog() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watchdog == nil {
+		return
+	}
+	s.watchdogExpired = true
+	for _, d := range s.devices {
+		for o := int(d.I16uOutputOffset); o < int(d.I16uOutputOffset)+int(d.I16uOutputLength); o++ {
+			s.image[o] = 0
+		}
+	}
+}
+
+// StopIO stops, starts or toggles the simulated I/O communication: while it is stopped
+// SetValue rejects inputs, as the driver does not update them from the modules.
+func (s *Simulator) StopIO(mode int) (stopped bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch mode {
+	case gopicontrol.IOStart:
+		s.ioStopped = false
+	case gopicontrol.IOStop:
+		s.ioStopped = true
+	case gopicontrol.IOToggle:
+		s.ioStopped = !s.ioStopped
+	default:
+		return s.ioStopped, driverError("KB_STOP_IO", unix.EINVAL)
+	}
+	return s.ioStopped, nil
+}
+
+// IOStopped reports whether the simulated I/O communication is stopped.
+func (s *Simulator) IOStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ioStopped
+}
+
+// ConfigStop starts a gateway configuration download, the I/O communication is stopped until ConfigStart.
+func (s *Simulator) ConfigStop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configuring = true
+	s.gatewayConfig = [2][]byte{}
+	return nil
+}
+
+// ConfigSend appends a chunk to the configuration of the gateway on the left or right of the RevPi Core,
+// which must be configured at an address below or above gopicontrol.REV_PI_DEV_FIRST_RIGHT.
+func (s *Simulator) ConfigSend(data *gopicontrol.SConfigData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.configuring {
+		return driverError("KB_CONFIG_SEND", unix.EFAULT)
+	}
+	if int(data.I16uLen) > len(data.AcData) {
+		return driverError("KB_CONFIG_SEND", unix.EINVAL)
+	}
+	left := data.BLeft != 0
+	found := false
+	for _, d := range s.devices {
+		if d.I8uAddress != 0 && (d.I8uAddress < gopicontrol.REV_PI_DEV_FIRST_RIGHT) == left {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return driverError("KB_CONFIG_SEND", unix.ENODEV)
+	}
+	side := 0
+	if left {
+		side = 1
+	}
+	s.gatewayConfig[side] = append(s.gatewayConfig[side], data.AcData[:data.I16uLen]...)
+	return nil
+}
+
+// ConfigStart ends a gateway configuration download and restarts the I/O communication.
+func (s *Simulator) ConfigStart() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configuring = false
+	s.ioStopped = false
+	return nil
+}
+
+// GatewayConfig returns the configuration downloaded to the left or right gateway since the last ConfigStop.
+func (s *Simulator) GatewayConfig(left bool) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if left {
+		return append([]byte(nil), s.gatewayConfig[1]...)
+	}
+	return append([]byte(nil), s.gatewayConfig[0]...)
+}
+
+// GetDeviceInfo fills devInfo with the device matching its address or, if the address is 0,
+// its module type. If both are 0 the RevPi Core at address 0 is returned.
+func (s *Simulator) GetDeviceInfo(devInfo *gopicontrol.SDeviceInfo) (result int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, d := range s.devices {
+		if (devInfo.I8uAddress != 0 && d.I8uAddress == devInfo.I8uAddress) ||
+			(devInfo.I8uAddress == 0 && devInfo.I16uModuleType != 0 && d.I16uModuleType == devInfo.I16uModuleType) ||
+			(devInfo.I8uAddress == 0 && devInfo.I16uModuleType == 0 && d.I8uAddress == 0) {
+			*devInfo = d
+			return i, nil
+		}
+	}
+	return 0, driverError("KB_GET_DEVICE_INFO", unix.ENXIO)
+}
+
+// GetDeviceInfoList returns the configured devices.
+func (s *Simulator) GetDeviceInfoList() ([]gopicontrol.SDeviceInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]gopicontrol.SDeviceInfo(nil), s.devices...), nil
+}
+
+// GetBitValue reads one bit, or the whole byte if I8uBit >= 8.
+func (s *Simulator) GetBitValue(pSpiValue *gopicontrol.SPIValue) error {
+	if pSpiValue.I16uAddress >= gopicontrol.ProcessImageLength {
+		return driverError("KB_GET_VALUE", unix.EFAULT)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := s.image[pSpiValue.I16uAddress]
+	if pSpiValue.I8uBit >= 8 {
+		pSpiValue.I8uValue = b
+	} else {
+		pSpiValue.I8uValue = (b >> pSpiValue.I8uBit) & 1
+	}
+	return nil
+}
+
+// SetBitValue writes one bit, or the whole byte if I8uBit >= 8.
+func (s *Simulator) SetBitValue(pSpiValue *gopicontrol.SPIValue) error {
+	if pSpiValue.I16uAddress >= gopicontrol.ProcessImageLength {
+		return driverError("KB_SET_VALUE", unix.EFAULT)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &s.image[pSpiValue.I16uAddress]
+	switch {
+	case pSpiValue.I8uBit >= 8:
+		*b = pSpiValue.I8uValue
+	case pSpiValue.I8uValue != 0:
+		*b |= 1 << pSpiValue.I8uBit
+	default:
+		*b &^= 1 << pSpiValue.I8uBit
+	}
+	return nil
+}
+
+// SetExportedOutputs copies the exported output entries from image like KB_SET_EXPORTED_OUTPUTS.
+func (s *Simulator) SetExportedOutputs(image []byte) error {
+	if len(image) != gopicontrol.ProcessImageLength {
+		return driverError("KB_SET_EXPORTED_OUTPUTS", unix.EINVAL)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		e := &s.entries[i]
+		if gopicontrol.EntryType(e.I8uType) != gopicontrol.EntryOutput|gopicontrol.EntryExported {
+			continue
+		}
+		o := int(e.I16uOffset)
+		if e.I16uBitLength == 1 {
+			mask := byte(1) << e.I8uBitPos
+			s.image[o] = s.image[o]&^mask | image[o]&mask
+			continue
+		}
+		copy(s.image[o:o+int(e.I16uBitLength+7)/8], image[o:])
+	}
+	return nil
+}
+
+// GetVariableInfo looks up a variable by name like KB_FIND_VARIABLE.
+func (s *Simulator) GetVariableInfo(name string) (*gopicontrol.SPIVariable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.names[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", gopicontrol.ErrVariableNotFound, name)
+	}
+	e := &s.entries[i]
+	return &gopicontrol.SPIVariable{
+		StrVarName:  e.StrVarName,
+		I16uAddress: e.I16uOffset,
+		I8uBit:      e.I8uBitPos,
+		I16uLength:  e.I16uBitLength,
+	}, nil
+}
+
+// ResetCounter sets to 0 the counters/encoders of a DIO or DI module selected by bitfield,
+// bit n selects the counter on input n+1.
+func (s *Simulator) ResetCounter(address uint8, bitfield uint16) (result int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, d := range s.devices {
+		if d.I8uAddress != address {
+			continue
+		}
+		if (d.I16uModuleType != gopicontrol.ModuleTypeDIO && d.I16uModuleType != gopicontrol.ModuleTypeDI) || bitfield == 0 {
+			return -1, driverError("KB_DIO_RESET_COUNTER", unix.EINVAL)
+		}
+		for n := uint(0); n < 16; n++ {
+			if bitfield&(1<<n) == 0 {
+				continue
+			}
+			o := int(d.I16uInputOffset) + gopicontrol.DIOCounterOffset + 4*int(n)
+			if o+4 <= int(d.I16uInputOffset)+int(d.I16uInputLength) {
+				binary.LittleEndian.PutUint32(s.image[o:], 0)
+			}
+		}
+		return 0, nil
+	}
+	return -1, driverError("KB_DIO_RESET_COUNTER", unix.EINVAL)
+}
+
+// WaitForEvent blocks until the simulator is reset and returns KB_EVENT_RESET.
+func (s *Simulator) WaitForEvent() (event int, err error) {
+	s.mu.Lock()
+	ch := s.event
+	s.mu.Unlock()
+	<-ch
+	return gopicontrol.KB_EVENT_RESET, nil
+}
+
+// LastMessage returns the message of the last failed firmware update.
+func (s *Simulator) LastMessage() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastMessage, nil
+}
+
+// UpdateFirmware is not supported by the simulator.
+func (s *Simulator) UpdateFirmware(addrP uint32) (result int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastMessage = "firmware update is not supported by the simulator"
+	return -1, &gopicontrol.DriverError{Op: "KB_UPDATE_DEVICE_FIRMWARE", Errno: unix.EOPNOTSUPP, Message: s.lastMessage}
+}
@@ -0,0 +1,219 @@
+package pisim
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mezzato/revpi/pkg/gopicontrol"
+)
+
+// testConfig is a RevPi Core at address 0 and a DIO at address 32 with a reduced set of variables.
+const testConfig = `{
+ "App": {"name": "PiCtory", "version": "1.4.0"},
+ "Summary": {"inpTotal": 15, "outTotal": 5},
+ "Devices": [
+  {
+   "GUID": "core", "id": "device_RevPiCore", "type": "BASE", "productType": "95", "position": "0",
+   "name": "RevPi Core", "offset": 0,
+   "inp": {"0": ["RevPiStatus", "0", "8", "0", false, "0000", "", ""]},
+   "out": {"0": ["RevPiLED", "3", "8", "1", false, "0001", "", ""]},
+   "mem": {}
+  },
+  {
+   "GUID": "dio", "id": "device_RevPiDIO", "type": "RIGHT", "productType": "96", "position": "32",
+   "name": "RevPi DIO", "offset": 2,
+   "inp": {
+    "0": ["I_1", "0", "1", "0", true, "0000", "", "0"],
+    "1": ["I_2", "0", "1", "0", true, "0001", "", "1"],
+    "2": ["InputStatus", "0", "16", "2", false, "0002", "", ""],
+    "3": ["OutputStatus", "0", "16", "4", false, "0003", "", ""],
+    "4": ["Counter_1", "0", "32", "6", false, "0004", "", ""],
+    "5": ["Counter_2", "0", "32", "10", false, "0005", "", ""]
+   },
+   "out": {
+    "0": ["O_1", "1", "1", "14", true, "0006", "", "0"],
+    "1": ["O_2", "0", "1", "14", true, "0007", "", "1"],
+    "2": ["Value", "500", "16", "15", false, "0008", "", ""]
+   },
+   "mem": {}
+  }
+ ]
+}`
+
+func newTestSimulator(t *testing.T) *Simulator {
+	t.Helper()
+	s, err := New(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLayout(t *testing.T) {
+	s := newTestSimulator(t)
+	devices, err := s.GetDeviceInfoList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("got %d devices, want 2", len(devices))
+	}
+	dio := devices[1]
+	if dio.I8uAddress != 32 || dio.I16uModuleType != 96 {
+		t.Errorf("got device %d of type %d, want 32 of type 96", dio.I8uAddress, dio.I16uModuleType)
+	}
+	if dio.I16uInputOffset != 2 || dio.I16uInputLength != 14 {
+		t.Errorf("got inputs at %d length %d, want 2 length 14", dio.I16uInputOffset, dio.I16uInputLength)
+	}
+	if dio.I16uOutputOffset != 16 || dio.I16uOutputLength != 3 {
+		t.Errorf("got outputs at %d length %d, want 16 length 3", dio.I16uOutputOffset, dio.I16uOutputLength)
+	}
+	if dio.I16uFirstEntry != 2 || dio.I16uEntries != 9 {
+		t.Errorf("got entries %d+%d, want 2+9", dio.I16uFirstEntry, dio.I16uEntries)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		offset uint16
+		bit    uint8
+		length uint16
+		value  uint32
+	}{
+		{"RevPiStatus", 0, 0, 8, gopicontrol.PICONTROL_STATUS_RUNNING},
+		{"RevPiLED", 1, 0, 8, 3},
+		{"I_2", 2, 1, 1, 0},
+		{"Counter_2", 12, 0, 32, 0},
+		{"O_1", 16, 0, 1, 1},
+		{"Value", 17, 0, 16, 500},
+	} {
+		v, err := s.GetVariableInfo(tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.I16uAddress != tc.offset || v.I8uBit != tc.bit || v.I16uLength != tc.length {
+			t.Errorf("%s: got offset %d bit %d length %d, want %d %d %d",
+				tc.name, v.I16uAddress, v.I8uBit, v.I16uLength, tc.offset, tc.bit, tc.length)
+		}
+		if got, _ := s.Value(tc.name); got != tc.value {
+			t.Errorf("%s: got default %d, want %d", tc.name, got, tc.value)
+		}
+	}
+
+	if _, err := s.GetVariableInfo("Missing"); !errors.Is(err, gopicontrol.ErrVariableNotFound) {
+		t.Errorf("got %v, want ErrVariableNotFound", err)
+	}
+}
+
+func TestBitAndByteValues(t *testing.T) {
+	s := newTestSimulator(t)
+
+	// bit 1 of the outputs byte, bit 0 (O_1) keeps its default
+	if err := s.SetBitValue(&gopicontrol.SPIValue{I16uAddress: 16, I8uBit: 1, I8uValue: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Bytes()[16]; got != 0x03 {
+		t.Errorf("got byte 0x%02x after setting bit 1, want 0x03", got)
+	}
+	v := gopicontrol.SPIValue{I16uAddress: 16, I8uBit: 1}
+	if err := s.GetBitValue(&v); err != nil || v.I8uValue != 1 {
+		t.Errorf("got bit %d (%v), want 1", v.I8uValue, err)
+	}
+
+	// I8uBit >= 8 addresses the whole byte
+	if err := s.SetBitValue(&gopicontrol.SPIValue{I16uAddress: 16, I8uBit: 8, I8uValue: 0xa4}); err != nil {
+		t.Fatal(err)
+	}
+	v = gopicontrol.SPIValue{I16uAddress: 16, I8uBit: 8}
+	if err := s.GetBitValue(&v); err != nil || v.I8uValue != 0xa4 {
+		t.Errorf("got byte 0x%02x (%v), want 0xa4", v.I8uValue, err)
+	}
+
+	if err := s.SetBitValue(&gopicontrol.SPIValue{I16uAddress: gopicontrol.ProcessImageLength}); err == nil {
+		t.Error("got no error for an offset outside of the process image")
+	}
+}
+
+func TestResetCounter(t *testing.T) {
+	s := newTestSimulator(t)
+	for _, name := range []string{"Counter_1", "Counter_2", "InputStatus"} {
+		if err := s.SetValue(name, 0x12345678); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := s.ResetCounter(32, 1<<1); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]uint32{"Counter_1": 0x12345678, "Counter_2": 0, "InputStatus": 0x5678} {
+		if got, _ := s.Value(name); got != want {
+			t.Errorf("%s: got 0x%x, want 0x%x", name, got, want)
+		}
+	}
+
+	if _, err := s.ResetCounter(0, 1); err == nil {
+		t.Error("got no error resetting the counters of the RevPi Core")
+	}
+	if _, err := s.ResetCounter(33, 1); err == nil {
+		t.Error("got no error resetting the counters of a missing module")
+	}
+}
+
+func TestOutputWatchdog(t *testing.T) {
+	s := newTestSimulator(t)
+	if err := s.SetOutputWatchdog(20); err != nil {
+		t.Fatal(err)
+	}
+	// writes feed the watchdog
+	for i := 0; i < 5; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if _, err := s.Write(17, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.WatchdogExpired() {
+		t.Fatal("watchdog expired while fed")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !s.WatchdogExpired() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !s.WatchdogExpired() {
+		t.Fatal("watchdog did not expire")
+	}
+	image := s.Bytes()
+	for o := 16; o < 19; o++ {
+		if image[o] != 0 {
+			t.Errorf("output byte %d is 0x%02x after the watchdog expired, want 0", o, image[o])
+		}
+	}
+	if image[1] != 0 {
+		t.Errorf("RevPiLED is %d after the watchdog expired, want 0", image[1])
+	}
+	if image[0] != gopicontrol.PICONTROL_STATUS_RUNNING {
+		t.Errorf("input RevPiStatus changed to 0x%02x", image[0])
+	}
+}
+
+func TestStopIORejectsInputs(t *testing.T) {
+	s := newTestSimulator(t)
+	stopped, err := s.StopIO(gopicontrol.IOStop)
+	if err != nil || !stopped {
+		t.Fatalf("got stopped %t (%v), want true", stopped, err)
+	}
+	if err = s.SetValue("I_1", 1); err == nil {
+		t.Error("got no error setting an input while the I/O communication is stopped")
+	}
+	if err = s.SetValue("O_2", 1); err != nil {
+		t.Errorf("setting an output while stopped: %v", err)
+	}
+
+	if stopped, err = s.StopIO(gopicontrol.IOToggle); err != nil || stopped {
+		t.Fatalf("got stopped %t (%v), want false", stopped, err)
+	}
+	if err = s.SetValue("I_1", 1); err != nil {
+		t.Error(err)
+	}
+}